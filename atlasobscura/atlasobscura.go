@@ -1,82 +1,122 @@
-package main
+// Package atlasobscura builds a feed of the articles and places published
+// by Atlas Obscura, read from the site itself or from its tweets.
+package atlasobscura
 
 import (
 	"context"
-	"io"
+	"fmt"
+	"html"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"regexp"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"duh-uh.com/app/rss-feeds/feedkit"
 	twitter "github.com/g8rswimmer/go-twitter"
-	"github.com/gorilla/feeds"
-	"github.com/patrickmn/go-cache"
 )
 
 const (
 	BearerTokenEnv  = "TWITTER_BEARER_TOKEN"
-	ScreenName      = "atlasobscura"
-	NumTweets       = 20
+	ScreenName      = "atlasobscura" // Default ScreenName
+	NumTweets       = 20             // Default NumTweets
 	FeedURL         = "https://www.atlasobscura.com"
 	FeedTitle       = "Atlas Obscura"
 	FeedDescription = "Atlas Obscura Tweets"
-	FeedAuthor      = "Venky"
-	FeedAuthorEmail = "venkytv@gmail.com"
-	Timeout         = 10 * time.Second
-	CacheInterval   = 30 * time.Minute
+	SiteDescription = "New articles and places on Atlas Obscura"
+	PostDescription = "Atlas Obscura Mastodon posts"
+	BskyDescription = "Atlas Obscura Bluesky posts"
+	Timeout         = 10 * time.Second    // Default FetchTimeout
+	CacheInterval   = 30 * time.Minute    // Default RefreshInterval
+	MaxFailureRatio = 0.5                 // Default MaxFailureRatio
+	HistorySize     = 100                 // Default HistorySize
+	HistoryAge      = 30 * 24 * time.Hour // Default HistoryAge
+	MaxTweetPages   = 10                  // Pages of new tweets read on a refresh
+	SourceName      = "atlasobscura"      // Name of the source in metrics
 )
 
 type FeedItem struct {
 	Title   string
 	Url     string
 	Created time.Time
+	Summary string `json:",omitempty"`
+	Image   string `json:",omitempty"` // Hero image URL
+	PostID  string `json:",omitempty"` // ID of the post linking to the item
 }
 
-type FeedConfig struct {
-	Cache             *cache.Cache
-	CacheTimeOverride time.Time // Override for testing
+// itemReader reads the current items of the feed, newest first
+type itemReader interface {
+	readItems(context.Context) ([]FeedItem, []feedkit.ItemError, error)
+}
+
+// resumer is implemented by readers which only read the posts newer than
+// the ones they read before. Sources resume them from the items in the
+// store, so that they do not read those posts again after a restart.
+type resumer interface {
+	resume(items []FeedItem)
 }
 
 type tweetReader interface {
-	getTweets(context.Context) ([]twitter.TweetObj, error)
+	getTweets(context.Context) ([]twitter.TweetObj, []feedkit.ItemError, error)
 }
 
-type authorize struct {
-	Token string
+// tweetItems reads the items linked from the tweets of a tweetReader. Only
+// the URLs of new tweets are resolved, and their items are merged into the
+// latest numItems items resolved before.
+type tweetItems struct {
+	reader          tweetReader
+	timeout         time.Duration
+	maxFailureRatio float64
+	numItems        int
+
+	mu       sync.Mutex
+	resolved resolvedItems
 }
 
-func (a authorize) Add(req *http.Request) {
-	req.Header.Add("Authorization", "Bearer "+a.Token)
+func newTweetItems(reader tweetReader, config Config) *tweetItems {
+	return &tweetItems{
+		reader:          reader,
+		timeout:         config.FetchTimeout,
+		maxFailureRatio: config.MaxFailureRatio,
+		numItems:        config.NumTweets,
+	}
 }
 
-var utm_re = regexp.MustCompile(`\?utm_.*$`)
+func (t *tweetItems) readItems(ctx context.Context) ([]FeedItem, []feedkit.ItemError, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-func genFeed(items []FeedItem, url string, createTime time.Time) (string, error) {
-	feed := &feeds.Feed{
-		Title:       FeedTitle,
-		Link:        &feeds.Link{Href: string(url)},
-		Description: FeedDescription,
-		Author:      &feeds.Author{Name: FeedAuthor, Email: FeedAuthorEmail},
-		Created:     createTime,
-	}
-	for _, item := range items {
-		feed.Add(&feeds.Item{
-			Title:   item.Title,
-			Link:    &feeds.Link{Href: item.Url},
-			Created: item.Created,
-		})
+	posts, failures, err := fetchFeedItems(ctx, t.reader)
+	if err != nil {
+		return nil, failures, err
 	}
+	items, fixFailures, err := t.resolved.add(ctx, posts, t.numItems, t.timeout, t.maxFailureRatio)
+	return items, append(failures, fixFailures...), err
+}
 
-	atom, err := feed.ToAtom()
-	if err != nil {
-		return "", err
+func (t *tweetItems) resume(items []FeedItem) {
+	if r, ok := t.reader.(resumer); ok {
+		r.resume(items)
 	}
-	return atom, nil
 }
 
+type authorize struct {
+	Token string
+}
+
+func (a authorize) Add(req *http.Request) {
+	req.Header.Add("Authorization", "Bearer "+a.Token)
+}
+
+var (
+	utm_re  = regexp.MustCompile(`\?utm_.*$`)
+	post_re = regexp.MustCompile(`(.*?)\s(https?://\S+)`)
+)
+
 func gen(items []FeedItem) <-chan FeedItem {
 	out := make(chan FeedItem)
 	go func() {
@@ -93,15 +133,32 @@ type Result struct {
 	Error error
 }
 
-func fixer(ctx context.Context, items <-chan FeedItem, c chan<- Result) {
+// resolveURL returns the URL a link leads to after redirects, without
+// tracking parameters
+func resolveURL(ctx context.Context, link string, timeout time.Duration) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return "", err
+	}
+	// Links are picked by whoever posted them
+	client := http.Client{
+		Timeout:   timeout,
+		Transport: publicTransport,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return utm_re.ReplaceAllString(resp.Request.URL.String(), ""), nil
+}
+
+func fixer(ctx context.Context, items <-chan FeedItem, c chan<- Result, timeout time.Duration) {
 	for item := range items {
-		client := http.Client{
-			Timeout: Timeout,
-		}
-		resp, err := client.Head(item.Url)
+		start := time.Now()
+		url, err := resolveURL(ctx, item.Url, timeout)
+		feedkit.ObserveUpstream(SourceName, "fixer", start, err)
 		if err == nil {
-			url := utm_re.ReplaceAllString(
-				resp.Request.URL.String(), "")
 			item.Url = url
 		}
 		select {
@@ -112,7 +169,10 @@ func fixer(ctx context.Context, items <-chan FeedItem, c chan<- Result) {
 	}
 }
 
-func fixAllUrls(ctx context.Context, items []FeedItem) ([]FeedItem, error) {
+// fixAllUrls resolves the final URLs of the feed items. Items whose URL
+// could not be resolved keep the original URL, and the call only fails
+// if the share of failed lookups is above maxFailureRatio.
+func fixAllUrls(ctx context.Context, items []FeedItem, timeout time.Duration, maxFailureRatio float64) ([]FeedItem, []feedkit.ItemError, error) {
 	items_chan := gen(items)
 
 	// Start a fixed number of channels to fix URLs
@@ -122,7 +182,7 @@ func fixAllUrls(ctx context.Context, items []FeedItem) ([]FeedItem, error) {
 	wg.Add(numFixers)
 	for i := 0; i < numFixers; i++ {
 		go func() {
-			fixer(ctx, items_chan, c)
+			fixer(ctx, items_chan, c, timeout)
 			wg.Done()
 		}()
 	}
@@ -132,29 +192,97 @@ func fixAllUrls(ctx context.Context, items []FeedItem) ([]FeedItem, error) {
 	}()
 
 	out := make([]FeedItem, 0)
+	failures := make([]feedkit.ItemError, 0)
 	for r := range c {
 		if r.Error != nil {
-			return nil, r.Error
+			log.Printf("Failed to fix URL %s: %v", r.Item.Url, r.Error)
+			failures = append(failures, feedkit.ItemError{
+				Item:  r.Item.Url,
+				Error: r.Error.Error(),
+			})
 		}
 		out = append(out, r.Item)
 	}
 
+	if feedkit.TooManyFailures(len(failures), len(items), maxFailureRatio) {
+		return nil, failures, fmt.Errorf("%d of %d URL lookups failed",
+			len(failures), len(items))
+	}
+
 	sort.Slice(out, func(i, j int) bool {
 		return out[i].Created.After(out[j].Created)
 	})
 
-	return out, nil
+	return out, failures, nil
+}
+
+// resolvedItems keeps the latest items of a reader with their URLs
+// resolved, so that only the URLs of the items of new posts are resolved
+// on later refreshes. Readers guard it with their own lock.
+type resolvedItems struct {
+	items   []FeedItem // Newest first
+	pending []FeedItem // Items of new posts whose URLs are not resolved yet
+}
+
+// add resolves the URLs of the items of new posts, newest first, and
+// merges them into the latest size items resolved before. The posts of a
+// failed refresh are not read again, so their items are resolved along
+// with the next ones.
+func (r *resolvedItems) add(ctx context.Context, posts []FeedItem, size int, timeout time.Duration, maxFailureRatio float64) ([]FeedItem, []feedkit.ItemError, error) {
+	posts = append(posts, r.pending...)
+	if len(posts) > size {
+		posts = posts[:size]
+	}
+	newer, failures, err := fixAllUrls(ctx, posts, timeout, maxFailureRatio)
+	if err != nil {
+		r.pending = posts
+		return nil, failures, err
+	}
+	r.pending = nil
+
+	// Posts read again, e.g. after the newest post read was deleted,
+	// replace their items
+	read := make(map[string]bool, len(newer))
+	for _, item := range newer {
+		read[item.PostID] = true
+	}
+	items := newer
+	for _, item := range r.items {
+		if item.PostID == "" || !read[item.PostID] {
+			items = append(items, item)
+		}
+	}
+	if len(items) > size {
+		items = items[:size]
+	}
+	r.items = items
+	return items, failures, nil
+}
+
+// twitterUser is the part of the user API of go-twitter read by
+// tweetReaderImpl
+type twitterUser interface {
+	LookupUsername(ctx context.Context, usernames []string, opts twitter.UserFieldOptions) (twitter.UserLookups, error)
+	Tweets(ctx context.Context, userID string, opts twitter.UserTimelineOpts) (*twitter.UserTimeline, error)
 }
 
+// tweetReaderImpl reads the tweets of ScreenName. It only asks for the
+// tweets newer than the ones read before on later reads, following
+// next_token pages.
 type tweetReaderImpl struct {
-	User      *twitter.User
-	TweetOpts twitter.UserTimelineOpts
+	User       twitterUser
+	ScreenName string
+	TweetOpts  twitter.UserTimelineOpts
+
+	mu      sync.Mutex
+	userID  string // ID of ScreenName, looked up on the first read
+	sinceID string // ID of the newest tweet read
 }
 
-func newTweetReader(ctx context.Context) tweetReaderImpl {
+func newTweetReader(config Config) (*tweetReaderImpl, error) {
 	token, ok := os.LookupEnv(BearerTokenEnv)
 	if !ok {
-		log.Fatal("Env var not set: ", BearerTokenEnv)
+		return nil, fmt.Errorf("env var not set: %s", BearerTokenEnv)
 	}
 
 	user := &twitter.User{
@@ -173,149 +301,331 @@ func newTweetReader(ctx context.Context) tweetReaderImpl {
 			twitter.TweetFieldCreatedAt,
 		},
 		UserFields: []twitter.UserField{},
-		MaxResults: NumTweets,
+		MaxResults: config.NumTweets,
 	}
 
-	return tweetReaderImpl{
-		User:      user,
-		TweetOpts: tweetOpts,
-	}
+	return &tweetReaderImpl{
+		User:       user,
+		ScreenName: config.ScreenName,
+		TweetOpts:  tweetOpts,
+	}, nil
 }
 
-func (r tweetReaderImpl) getTweets(ctx context.Context) ([]twitter.TweetObj, error) {
-	lookups, err := r.User.LookupUsername(ctx, []string{ScreenName},
+// lookupUser returns the ID of ScreenName, which is only looked up once
+func (r *tweetReaderImpl) lookupUser(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.userID != "" {
+		return r.userID, nil
+	}
+	start := time.Now()
+	lookups, err := r.User.LookupUsername(ctx, []string{r.ScreenName},
 		twitter.UserFieldOptions{})
+	feedkit.ObserveUpstream(SourceName, "lookupUsername", start, err)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-
-	var userID string
 	for u := range lookups {
-		userID = u
+		r.userID = u
 		break
 	}
+	if r.userID == "" {
+		return "", fmt.Errorf("no Twitter user %s", r.ScreenName)
+	}
+	return r.userID, nil
+}
 
-	tweets, err := r.User.Tweets(ctx, userID, r.TweetOpts)
-	if err != nil {
-		return nil, err
+// newerID reports whether tweet ID a is newer than b. IDs are numbers
+// which grow over time, and may be empty.
+func newerID(a string, b string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
 	}
-	return tweets.Tweets, err
+	return a > b
 }
 
-func fetchFeedItems(ctx context.Context, reader tweetReader) ([]FeedItem, error) {
-	feedItems := make([]FeedItem, 0)
-	tweet_re := regexp.MustCompile(`(.*?)\s(https?://.*)`)
+// resume makes the reader only ask for the tweets newer than the items
+func (r *tweetReaderImpl) resume(items []FeedItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	tweets, err := reader.getTweets(ctx)
-	if err != nil {
-		return feedItems, err
-	}
-	for _, message := range tweets {
-		tweet := message.Text
-		t := tweet_re.FindStringSubmatch(tweet)
-		if len(t) < 2 {
-			log.Println("No URL in tweet: ", tweet)
-			continue
+	for _, item := range items {
+		if newerID(item.PostID, r.sinceID) {
+			r.sinceID = item.PostID
 		}
-		text := t[1]
-		url := t[2]
+	}
+}
 
-		createdAt, err := time.Parse(time.RFC3339, message.CreatedAt)
+// newTweets returns the tweets newer than sinceID, newest first. The first
+// read only asks for the latest tweets, later reads follow up to
+// MaxTweetPages pages of newer tweets. It also reports whether there were
+// more pages left, whose older tweets are skipped.
+func (r *tweetReaderImpl) newTweets(ctx context.Context, userID string) ([]twitter.TweetObj, bool, error) {
+	opts := r.TweetOpts
+	opts.SinceID = r.sinceID
+	tweets := make([]twitter.TweetObj, 0)
+	for page := 0; page < MaxTweetPages; page++ {
+		timeline, err := r.User.Tweets(ctx, userID, opts)
 		if err != nil {
-			log.Printf("Failed to parse time: %v: %v", message, err)
-			continue
+			return nil, false, err
 		}
-		feedItems = append(feedItems, FeedItem{
-			Title:   text,
-			Url:     url,
-			Created: createdAt,
-		})
+		tweets = append(tweets, timeline.Tweets...)
+		if r.sinceID == "" || timeline.Meta.NextToken == "" {
+			return tweets, false, nil
+		}
+		opts.PaginationToken = timeline.Meta.NextToken
 	}
+	return tweets, true, nil
+}
 
-	feedItems, err = fixAllUrls(ctx, feedItems)
+// getTweets returns the tweets posted since the last read, newest first.
+// Tweets skipped after MaxTweetPages pages are reported as a failure.
+func (r *tweetReaderImpl) getTweets(ctx context.Context) ([]twitter.TweetObj, []feedkit.ItemError, error) {
+	userID, err := r.lookupUser(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
 
-	return feedItems, nil
-}
-
-func cacheFeed(ctx context.Context, reader tweetReader, feedConfig FeedConfig) {
-	log.Print("Caching feed")
-	feedItems, err := fetchFeedItems(ctx, reader)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sinceID := r.sinceID
+	newer, more, err := r.newTweets(ctx, userID)
 	if err != nil {
-		log.Printf("Failed to update cache: %v\n", err)
-		return
+		return nil, nil, err
+	}
+	if len(newer) > 0 && newerID(newer[0].ID, r.sinceID) {
+		r.sinceID = newer[0].ID
+	}
+
+	var failures []feedkit.ItemError
+	if more {
+		log.Printf("More than %d pages of tweets by %s since %s, skipping the older ones",
+			MaxTweetPages, r.ScreenName, sinceID)
+		failures = append(failures, feedkit.ItemError{
+			Item:  "@" + r.ScreenName,
+			Error: fmt.Sprintf("tweets since %s skipped after %d pages", sinceID, MaxTweetPages),
+		})
 	}
+	return newer, failures, nil
+}
 
-	feedTime := feedConfig.CacheTimeOverride
-	if feedTime.IsZero() {
-		feedTime = time.Now()
+// postItem builds the feed item of a post of the form "text URL", linking
+// to the first URL in the post
+func postItem(text string, created time.Time) (FeedItem, bool) {
+	t := post_re.FindStringSubmatch(text)
+	if len(t) < 2 {
+		log.Println("No URL in post: ", text)
+		return FeedItem{}, false
 	}
+	return FeedItem{
+		Title:   t[1],
+		Url:     t[2],
+		Created: created,
+	}, true
+}
+
+// fetchFeedItems returns the items of the new tweets of the reader, with
+// their URLs as posted
+func fetchFeedItems(ctx context.Context, reader tweetReader) ([]FeedItem, []feedkit.ItemError, error) {
+	feedItems := make([]FeedItem, 0)
 
-	feed, err := genFeed(feedItems, FeedURL, feedTime)
+	start := time.Now()
+	tweets, failures, err := reader.getTweets(ctx)
+	feedkit.ObserveUpstream(SourceName, "getTweets", start, err)
 	if err != nil {
-		log.Fatal(err)
+		return feedItems, failures, err
+	}
+	for _, message := range tweets {
+		createdAt, err := time.Parse(time.RFC3339, message.CreatedAt)
+		if err != nil {
+			log.Printf("Failed to parse time: %v: %v", message, err)
+			continue
+		}
+		if item, ok := postItem(message.Text, createdAt); ok {
+			item.PostID = message.ID
+			feedItems = append(feedItems, item)
+		}
 	}
+	return feedItems, failures, nil
+}
 
-	feedConfig.Cache.Set("feed", feed, cache.NoExpiration)
+// Source is the feed of the articles and places of Atlas Obscura, or of
+// the links posted by a social account
+type Source struct {
+	reader  itemReader
+	account *Account // Set for the feeds of social accounts
+	Config  Config
+	Store   feedkit.Store
 }
 
-func fetchCachedFeed(ctx context.Context, reader tweetReader, feedConfig FeedConfig) string {
-	feed, found := feedConfig.Cache.Get("feed")
-	if !found {
-		log.Print("Cached feed not found")
-		cacheFeed(ctx, reader, feedConfig)
-		feed, found = feedConfig.Cache.Get("feed")
+// NewSource returns a source reading the pages of site, or the posts of
+// the configured Mastodon account, Bluesky handle or Twitter user. Tweets
+// are read with the bearer token in the BearerTokenEnv environment
+// variable.
+func NewSource(site Site, config Config, store feedkit.Store) (*Source, error) {
+	var reader itemReader
+	switch config.Reader {
+	case ReaderTwitter:
+		tweets, err := newTweetReader(config)
+		if err != nil {
+			return nil, err
+		}
+		reader = newTweetItems(tweets, config)
+	case ReaderMastodon:
+		posts, err := newMastodonReader(config)
+		if err != nil {
+			return nil, err
+		}
+		reader = posts
+	case ReaderBluesky:
+		posts, err := newBlueskyReader(BlueskyAPI, config)
+		if err != nil {
+			return nil, err
+		}
+		reader = posts
+	default:
+		reader = newSiteReader(site, config)
 	}
-	return feed.(string)
+	return &Source{
+		reader: reader,
+		Config: config,
+		Store:  store,
+	}, nil
 }
 
-func feedHandler(ctx context.Context, reader tweetReader, feedConfig FeedConfig) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		io.WriteString(w, fetchCachedFeed(ctx, reader, feedConfig))
-	})
+func (s *Source) Metadata() feedkit.Metadata {
+	if s.account != nil {
+		return s.account.Metadata()
+	}
+	description := FeedDescription
+	switch s.Config.Reader {
+	case ReaderSite:
+		description = SiteDescription
+	case ReaderMastodon:
+		description = PostDescription
+	case ReaderBluesky:
+		description = BskyDescription
+	}
+	return feedkit.Metadata{
+		Title:       FeedTitle,
+		Link:        FeedURL,
+		Description: description,
+	}
 }
 
-func main() {
-	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
-	defer cancel() // Cancel context once feeds are fetched
-
-	feedConfig := FeedConfig{
-		Cache: cache.New(0, 0), // Cache feeds indefinitely
+// itemContent renders the hero image and the summary of an item
+func itemContent(item FeedItem) string {
+	if item.Image == "" {
+		return ""
 	}
+	content := fmt.Sprintf(`<img src="%s" alt="">`, html.EscapeString(item.Image))
+	if item.Summary != "" {
+		content += "<p>" + html.EscapeString(item.Summary) + "</p>"
+	}
+	return content
+}
 
-	reader := newTweetReader(ctx)
+// imageType guesses the MIME type of an image from its URL. Image CDNs
+// such as Bluesky's name the format after an "@", e.g. ".../abc@jpeg".
+func imageType(imageURL string) string {
+	name := path.Base(imageURL)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	ext := path.Ext(name)
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		ext = "." + name[i+1:]
+	}
+	switch strings.ToLower(ext) {
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	default:
+		return "image/jpeg"
+	}
+}
 
-	// Cache feed at startup
-	cacheFeed(ctx, reader, feedConfig)
+func toItems(feedItems []FeedItem) []feedkit.Item {
+	items := make([]feedkit.Item, 0, len(feedItems))
+	for _, item := range feedItems {
+		var enclosure *feedkit.Enclosure
+		if item.Image != "" {
+			enclosure = &feedkit.Enclosure{URL: item.Image, Type: imageType(item.Image)}
+		}
+		items = append(items, feedkit.Item{
+			Title:       item.Title,
+			Link:        item.Url,
+			Description: html.EscapeString(item.Summary),
+			Content:     itemContent(item),
+			Created:     item.Created,
+			Enclosure:   enclosure,
+		})
+	}
+	return items
+}
 
-	ticker := time.NewTicker(CacheInterval)
-	defer ticker.Stop()
+// historyBucket returns the store bucket of the items of the feed
+func (s *Source) historyBucket() string {
+	if s.account != nil {
+		return itemsBucket + "/" + s.account.Path()
+	}
+	return itemsBucket
+}
 
-	done := make(chan bool)
+func (s *Source) Fetch(ctx context.Context) ([]feedkit.Item, []feedkit.ItemError, error) {
+	feedItems, failures, err := s.reader.readItems(ctx)
+	if err != nil {
+		return nil, failures, err
+	}
 
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				cacheFeed(ctx, reader, feedConfig)
-			}
+	if s.Store != nil {
+		feedItems, err = itemHistory(s.Store, s.historyBucket(), feedItems, s.Config.historyLimits(), time.Now())
+		if err != nil {
+			log.Print("Failed to update feed history: ", err)
 		}
-	}()
+	}
+	return toItems(feedItems), failures, nil
+}
 
-	log.Print("Starting server")
-	srv := http.Server{
-		Addr:         ":8080",
-		ReadTimeout:  Timeout / 2.0,
-		WriteTimeout: Timeout,
-		Handler: http.TimeoutHandler(feedHandler(ctx, reader, feedConfig),
-			Timeout, "Timeout!\n"),
+// Load returns the feed items in the store, so that the feed can be served
+// before it is first fetched, and resumes the reader from them
+func (s *Source) Load(ctx context.Context) ([]feedkit.Item, error) {
+	if s.Store == nil {
+		return nil, nil
 	}
+	feedItems, err := itemHistory(s.Store, s.historyBucket(), nil, s.Config.historyLimits(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := s.reader.(resumer); ok {
+		r.resume(feedItems)
+	}
+	return toItems(feedItems), nil
+}
+
+// Mount serves the feed on path, and the feeds of social accounts below
+// path on /social/{platform}/{handle}. The configured accounts are
+// refreshed in the background and keep their history in the store, while
+// other accounts are read on request.
+func Mount(server *feedkit.Server, path string, config Config, store feedkit.Store) error {
+	source, err := NewSource(DefaultSite(), config, store)
+	if err != nil {
+		return err
+	}
+	server.Mount(path, source, config.RefreshInterval)
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed: %v\n", err)
+	prefix := strings.TrimSuffix(path, "/") + "/social/"
+	for _, account := range config.Accounts {
+		source, err := NewAccountSource(account, config, store)
+		if err != nil {
+			return err
+		}
+		server.Mount(prefix+source.account.Path(), source, source.Config.RefreshInterval)
 	}
+	server.MountSet(prefix, newSocialSource(config), config.RefreshInterval)
+	return nil
 }
@@ -1,17 +1,23 @@
-package main
+package atlasobscura
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"duh-uh.com/app/rss-feeds/feedkit"
 	twitter "github.com/g8rswimmer/go-twitter"
-	"github.com/patrickmn/go-cache"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,8 +33,62 @@ type mockTweetReader struct {
 	FeedItems []FeedItem
 }
 
-func (reader mockTweetReader) getTweets(context.Context) ([]twitter.TweetObj, error) {
-	return reader.Tweets, nil
+func (reader mockTweetReader) getTweets(context.Context) ([]twitter.TweetObj, []feedkit.ItemError, error) {
+	return reader.Tweets, nil, nil
+}
+
+// mockTwitterUser serves the timeline of one user, newest first, in pages
+// of MaxResults tweets. It counts the username lookups and records the
+// timeline requests.
+type mockTwitterUser struct {
+	ID       string
+	Timeline []twitter.TweetObj
+	lookups  int
+	requests []twitter.UserTimelineOpts
+}
+
+func (u *mockTwitterUser) LookupUsername(ctx context.Context, usernames []string, opts twitter.UserFieldOptions) (twitter.UserLookups, error) {
+	u.lookups++
+	return twitter.UserLookups{u.ID: twitter.UserLookup{}}, nil
+}
+
+func (u *mockTwitterUser) Tweets(ctx context.Context, userID string, opts twitter.UserTimelineOpts) (*twitter.UserTimeline, error) {
+	if userID != u.ID {
+		return nil, errors.New("unknown user")
+	}
+	u.requests = append(u.requests, opts)
+	newer := make([]twitter.TweetObj, 0)
+	for _, tweet := range u.Timeline {
+		if newerID(tweet.ID, opts.SinceID) {
+			newer = append(newer, tweet)
+		}
+	}
+	offset, _ := strconv.Atoi(opts.PaginationToken)
+	timeline := &twitter.UserTimeline{Tweets: newer[offset:]}
+	if len(timeline.Tweets) > opts.MaxResults {
+		timeline.Tweets = timeline.Tweets[:opts.MaxResults]
+		timeline.Meta.NextToken = strconv.Itoa(offset + opts.MaxResults)
+	}
+	return timeline, nil
+}
+
+// Post adds a tweet linking to url
+func (u *mockTwitterUser) Post(id int, url string) {
+	u.Timeline = append([]twitter.TweetObj{{
+		ID:        strconv.Itoa(id),
+		Text:      fmt.Sprintf("Tweet %d %s", id, url),
+		CreatedAt: time.Date(2021, time.May, 2, 0, id, 0, 0, time.UTC).Format(time.RFC3339),
+	}}, u.Timeline...)
+}
+
+// staticSource serves the feed items it is given
+type staticSource struct {
+	Source
+	Items []feedkit.Item
+}
+
+func (s *staticSource) Fetch(context.Context) ([]feedkit.Item, []feedkit.ItemError, error) {
+	return s.Items, nil, nil
 }
 
 func TestFixAllUrls(t *testing.T) {
@@ -38,7 +98,7 @@ func TestFixAllUrls(t *testing.T) {
 
 	t.Run("EmptyFeed", func(t *testing.T) {
 		feedItems = nil
-		feedItems, err = fixAllUrls(ctx, feedItems)
+		feedItems, _, err = fixAllUrls(ctx, feedItems, Timeout, MaxFailureRatio)
 		assert.Nil(t, err)
 	})
 
@@ -71,22 +131,75 @@ func TestFixAllUrls(t *testing.T) {
 				Created: item.Created,
 			})
 		}
-		feedItems, err = fixAllUrls(ctx, feedItems)
+		var failures []feedkit.ItemError
+		feedItems, failures, err = fixAllUrls(ctx, feedItems, Timeout, MaxFailureRatio)
 		assert.Nil(t, err)
+		assert.Empty(t, failures)
 		assert.Len(t, feedItems, 3)
 		assert.Equal(t, wantItems, feedItems)
 
-		feed, err := genFeed(feedItems,
-			FeedURL,
-			time.Date(2021, time.May, 2, 15, 0, 0, 0, time.UTC),
-		)
+		feedConfig := feedkit.NewFeedConfig()
+		feedConfig.CacheTimeOverride = time.Date(2021, time.May, 2, 15, 0, 0, 0, time.UTC)
+		source := &staticSource{Items: toItems(feedItems)}
+		snapshot, err := feedkit.NewFeed("/", source, feedConfig).Snapshot(ctx)
 		assert.Nil(t, err)
 		bytes, err = ioutil.ReadFile("testdata/feed.xml")
 		if err != nil {
 			t.Fatal(err)
 		}
 		wantFeed := strings.TrimSuffix(string(bytes), "\n")
-		assert.Equal(t, wantFeed, feed)
+		assert.Equal(t, wantFeed, string(snapshot.Bodies[feedkit.FormatAtom].Data))
+	})
+}
+
+func TestFixAllUrlsPartialFailure(t *testing.T) {
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/short" {
+				http.Redirect(w, r, "/articles/fixed?utm_source=twitter",
+					http.StatusMovedPermanently)
+			}
+		}))
+	defer srv.Close()
+
+	// A server which is no longer listening
+	deadSrv := httptest.NewServer(http.NotFoundHandler())
+	deadSrv.Close()
+
+	created := time.Date(2021, time.May, 2, 14, 0, 0, 0, time.UTC)
+	items := []FeedItem{
+		{Title: "Fixed", Url: srv.URL + "/short", Created: created},
+		{Title: "Unfixed", Url: deadSrv.URL + "/short", Created: created.Add(-time.Hour)},
+	}
+
+	t.Run("SingleFailure", func(t *testing.T) {
+		feedItems, failures, err := fixAllUrls(ctx, items, Timeout, MaxFailureRatio)
+		assert.Nil(t, err)
+		assert.Equal(t, []FeedItem{
+			{Title: "Fixed", Url: srv.URL + "/articles/fixed", Created: created},
+			items[1],
+		}, feedItems)
+		assert.Len(t, failures, 1)
+		assert.Equal(t, deadSrv.URL+"/short", failures[0].Item)
+	})
+
+	t.Run("TooManyFailures", func(t *testing.T) {
+		feedItems, failures, err := fixAllUrls(ctx, items[1:], Timeout, MaxFailureRatio)
+		assert.NotNil(t, err)
+		assert.Nil(t, feedItems)
+		assert.Len(t, failures, 1)
+	})
+
+	t.Run("MaxFailureRatio", func(t *testing.T) {
+		// A single failure is too many when no failures are allowed
+		_, _, err := fixAllUrls(ctx, items, Timeout, 0)
+		assert.NotNil(t, err)
+		feedItems, failures, err := fixAllUrls(ctx, items[1:], Timeout, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, items[1:], feedItems)
+		assert.Len(t, failures, 1)
 	})
 }
 
@@ -98,7 +211,7 @@ func TestFetchFeedItems(t *testing.T) {
 			Tweets:    []twitter.TweetObj{},
 			FeedItems: []FeedItem{},
 		}
-		feedItems, err := fetchFeedItems(ctx, reader)
+		feedItems, _, err := newTweetItems(reader, DefaultConfig()).readItems(ctx)
 		assert.Nil(t, err)
 		assert.Equal(t, reader.FeedItems, feedItems)
 	})
@@ -152,7 +265,7 @@ func TestFetchFeedItems(t *testing.T) {
 			Tweets:    tweets,
 			FeedItems: feedItems,
 		}
-		feedItems, err := fetchFeedItems(ctx, reader)
+		feedItems, _, err := newTweetItems(reader, DefaultConfig()).readItems(ctx)
 		assert.Nil(t, err)
 		assert.Equal(t, reader.FeedItems, feedItems)
 	})
@@ -165,10 +278,8 @@ func TestFetchCachedFeed(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	feedConfig := FeedConfig{
-		Cache:             cache.New(0, 0),
-		CacheTimeOverride: cacheTime,
-	}
+	feedConfig := feedkit.NewFeedConfig()
+	feedConfig.CacheTimeOverride = cacheTime
 
 	reader := mockTweetReader{
 		Tweets: []twitter.TweetObj{
@@ -178,21 +289,231 @@ func TestFetchCachedFeed(t *testing.T) {
 			},
 		},
 	}
+	config := DefaultConfig()
+	config.Reader = ReaderTwitter
+	source := &Source{reader: newTweetItems(reader, config), Config: config}
+	feed := feedkit.NewFeed("/", source, feedConfig)
+
 	bytes, err := ioutil.ReadFile("testdata/cached_feed.xml")
 	if err != nil {
 		t.Fatal(err)
 	}
 	wantFeed := strings.TrimSuffix(string(bytes), "\n")
-	cachedFeed := fetchCachedFeed(ctx, reader, feedConfig)
-	assert.Equal(t, wantFeed, cachedFeed)
+	cachedFeed, err := feed.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, wantFeed, string(cachedFeed.Bodies[feedkit.FormatAtom].Data))
+	assert.Equal(t, 1, cachedFeed.Version)
 
 	time.Sleep(1 * time.Second)
-	cachedFeed = fetchCachedFeed(ctx, reader, feedConfig)
-	assert.Equal(t, wantFeed, cachedFeed)
+	cachedFeed, err = feed.Snapshot(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, wantFeed, string(cachedFeed.Bodies[feedkit.FormatAtom].Data))
+
+	// Refreshing an unchanged feed keeps the snapshot
+	cachedFeed, err = feed.Refresh(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, cachedFeed.Version)
+}
+
+func TestTweetReader(t *testing.T) {
+	ctx := context.Background()
+	user := &mockTwitterUser{ID: "42"}
+	for id := 1; id <= 12; id++ {
+		user.Post(id, "https://www.atlasobscura.com/articles/"+strconv.Itoa(id))
+	}
+	reader := &tweetReaderImpl{
+		User:       user,
+		ScreenName: "atlasobscura",
+		TweetOpts:  twitter.UserTimelineOpts{MaxResults: 5},
+	}
+
+	// The first read only asks for the latest tweets
+	tweets, failures, err := reader.getTweets(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, failures)
+	if assert.Len(t, tweets, 5) {
+		assert.Equal(t, "12", tweets[0].ID)
+		assert.Equal(t, "8", tweets[4].ID)
+	}
+	assert.Len(t, user.requests, 1)
+
+	t.Run("SinceID", func(t *testing.T) {
+		for id := 13; id <= 24; id++ {
+			user.Post(id, "https://www.atlasobscura.com/articles/"+strconv.Itoa(id))
+		}
+		user.requests = nil
+		tweets, _, err := reader.getTweets(ctx)
+		assert.Nil(t, err)
+		// All new tweets, without the ones read before
+		if assert.Len(t, tweets, 12) {
+			assert.Equal(t, "24", tweets[0].ID)
+			assert.Equal(t, "13", tweets[11].ID)
+		}
+		if assert.Len(t, user.requests, 3) {
+			assert.Equal(t, "12", user.requests[0].SinceID)
+			assert.Equal(t, "", user.requests[0].PaginationToken)
+			assert.Equal(t, "10", user.requests[2].PaginationToken)
+		}
+
+		user.requests = nil
+		tweets, _, err = reader.getTweets(ctx)
+		assert.Nil(t, err)
+		assert.Empty(t, tweets)
+		assert.Equal(t, "24", user.requests[0].SinceID)
+		// The user ID is only looked up once
+		assert.Equal(t, 1, user.lookups)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		store := feedkit.NewMemoryStore()
+		config := DefaultConfig()
+		config.Reader = ReaderTwitter
+		source := &Source{reader: newTweetItems(reader, config), Config: config, Store: store}
+		items := []FeedItem{
+			{Title: "Tweet 30", Url: "https://www.atlasobscura.com/articles/30", PostID: "30"},
+			{Title: "Tweet 100", Url: "https://www.atlasobscura.com/articles/100", PostID: "100"},
+		}
+		if _, err := itemHistory(store, itemsBucket, items, config.historyLimits(), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := source.Load(ctx)
+		assert.Nil(t, err)
+		user.requests = nil
+		_, _, err = reader.getTweets(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "100", user.requests[0].SinceID)
+	})
+
+	t.Run("MaxTweetPages", func(t *testing.T) {
+		for id := 101; id <= 100+5*MaxTweetPages+3; id++ {
+			user.Post(id, "https://www.atlasobscura.com/articles/"+strconv.Itoa(id))
+		}
+		user.requests = nil
+		tweets, failures, err := reader.getTweets(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, user.requests, MaxTweetPages)
+		if assert.Len(t, tweets, 5*MaxTweetPages) {
+			assert.Equal(t, "153", tweets[0].ID)
+		}
+		if assert.Len(t, failures, 1) {
+			assert.Equal(t, "@atlasobscura", failures[0].Item)
+		}
+	})
+}
+
+func TestTweetItems(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	resolved := make(map[string]int)
+	down := false
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if down {
+				panic(http.ErrAbortHandler)
+			}
+			resolved[r.URL.Path]++
+		}))
+	defer srv.Close()
+
+	user := &mockTwitterUser{ID: "42"}
+	for id := 1; id <= 5; id++ {
+		user.Post(id, srv.URL+"/articles/"+strconv.Itoa(id))
+	}
+	config := DefaultConfig()
+	config.NumTweets = 5
+	reader := &tweetReaderImpl{
+		User:       user,
+		ScreenName: "atlasobscura",
+		TweetOpts:  twitter.UserTimelineOpts{MaxResults: config.NumTweets},
+	}
+	tweets := newTweetItems(reader, config)
+
+	items, failures, err := tweets.readItems(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, failures)
+	assert.Len(t, items, 5)
+
+	// Only the URLs of the new tweets are resolved, and the items read
+	// before are kept up to NumTweets
+	user.Post(6, srv.URL+"/articles/6")
+	user.Post(7, srv.URL+"/articles/7")
+	items, failures, err = tweets.readItems(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, failures)
+	if assert.Len(t, items, 5) {
+		assert.Equal(t, "Tweet 7", items[0].Title)
+		assert.Equal(t, "7", items[0].PostID)
+		assert.Equal(t, "Tweet 3", items[4].Title)
+	}
+	mu.Lock()
+	assert.Len(t, resolved, 7)
+	for path, n := range resolved {
+		assert.Equal(t, 1, n, path)
+	}
+	mu.Unlock()
+
+	t.Run("FailedRefresh", func(t *testing.T) {
+		mu.Lock()
+		down = true
+		mu.Unlock()
+		user.Post(8, srv.URL+"/articles/8")
+		_, failures, err := tweets.readItems(ctx)
+		assert.NotNil(t, err)
+		assert.Len(t, failures, 1)
+
+		// The tweet is not read again, but its item is still resolved
+		mu.Lock()
+		down = false
+		mu.Unlock()
+		items, failures, err := tweets.readItems(ctx)
+		assert.Nil(t, err)
+		assert.Empty(t, failures)
+		if assert.Len(t, items, 5) {
+			assert.Equal(t, "Tweet 8", items[0].Title)
+			assert.Equal(t, "Tweet 4", items[4].Title)
+		}
+	})
+}
+
+func TestNewerID(t *testing.T) {
+	assert.True(t, newerID("1394748397829455876", "999999999999999999"))
+	assert.True(t, newerID("12", "11"))
+	assert.True(t, newerID("1", ""))
+	assert.False(t, newerID("", "1"))
+	assert.False(t, newerID("11", "11"))
+}
+
+func TestLoad(t *testing.T) {
+	store := feedkit.NewMemoryStore()
+	created := time.Date(2021, time.May, 2, 14, 0, 26, 0, time.UTC)
+	horse := FeedItem{
+		Title:   "This Dalecarlian horse is about the size of a pinhead.",
+		Url:     "https://www.atlasobscura.com/articles/smallest-dala-horse",
+		Created: created,
+	}
+	config := DefaultConfig()
+	if _, err := itemHistory(store, itemsBucket, []FeedItem{horse}, config.historyLimits(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := (&Source{Store: store}).Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, toItems([]FeedItem{horse}), items)
+
+	items, err = (&Source{}).Load(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, items)
 }
 
 func TestMain(m *testing.M) {
 	// Skip log messages during testing
 	log.SetOutput(ioutil.Discard)
+	// Mock servers listen on loopback addresses
+	publicTransport = http.DefaultTransport
 	os.Exit(m.Run())
 }
@@ -0,0 +1,247 @@
+package atlasobscura
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+)
+
+const (
+	BlueskyAPI        = "https://public.api.bsky.app" // Public AppView, no login needed
+	BlueskyFeedPath   = "/xrpc/app.bsky.feed.getAuthorFeed"
+	BlueskyMaxPosts   = 100 // Max posts per page of getAuthorFeed
+	blueskyLinkFacet  = "app.bsky.richtext.facet#link"
+	blueskyEmbedView  = "app.bsky.embed.external#view"
+	blueskyMediaEmbed = "app.bsky.embed.recordWithMedia#view"
+)
+
+var handleRE = regexp.MustCompile(`^@?([A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+)$`)
+
+// blueskyExternal is the card of a link embedded in a post
+type blueskyExternal struct {
+	URI         string `json:"uri"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Thumb       string `json:"thumb"` // Image URL in views
+}
+
+type blueskyEmbed struct {
+	Type     string           `json:"$type"`
+	External *blueskyExternal `json:"external"`
+	Media    *blueskyEmbed    `json:"media"` // Posts quoting a post
+}
+
+type blueskyFacet struct {
+	Index struct {
+		ByteStart int `json:"byteStart"`
+		ByteEnd   int `json:"byteEnd"`
+	} `json:"index"`
+	Features []struct {
+		Type string `json:"$type"`
+		URI  string `json:"uri"`
+	} `json:"features"`
+}
+
+// BlueskyPost is a post of an author feed
+type BlueskyPost struct {
+	URI    string `json:"uri"`
+	Record struct {
+		Text      string         `json:"text"`
+		CreatedAt time.Time      `json:"createdAt"`
+		Facets    []blueskyFacet `json:"facets"`
+	} `json:"record"`
+	Embed *blueskyEmbed `json:"embed"`
+}
+
+type blueskyFeed struct {
+	Feed []struct {
+		Post   BlueskyPost      `json:"post"`
+		Reason *json.RawMessage `json:"reason"` // Set for reposts
+	} `json:"feed"`
+}
+
+// external returns the link card embedded in the post, if any
+func (p BlueskyPost) external() *blueskyExternal {
+	embed := p.Embed
+	if embed != nil && embed.Type == blueskyMediaEmbed {
+		embed = embed.Media
+	}
+	if embed == nil || embed.Type != blueskyEmbedView {
+		return nil
+	}
+	return embed.External
+}
+
+// link returns the first link facet of the post, along with the text of
+// the post without the link
+func (p BlueskyPost) link() (string, string) {
+	text := p.Record.Text
+	for _, facet := range p.Record.Facets {
+		for _, feature := range facet.Features {
+			start, end := facet.Index.ByteStart, facet.Index.ByteEnd
+			if feature.Type != blueskyLinkFacet || start < 0 || start > end || end > len(text) {
+				continue
+			}
+			return feature.URI, strings.Join(strings.Fields(text[:start]+text[end:]), " ")
+		}
+	}
+	return "", text
+}
+
+// blueskyItem builds the feed item of a post from its link card or its
+// link facets, falling back to the URL in its text
+func blueskyItem(post BlueskyPost) (FeedItem, bool) {
+	if card := post.external(); card != nil && card.URI != "" {
+		item := FeedItem{
+			Title:   card.Title,
+			Url:     card.URI,
+			Created: post.Record.CreatedAt,
+			Summary: card.Description,
+			Image:   card.Thumb,
+		}
+		if item.Title == "" {
+			_, item.Title = post.link()
+		}
+		return item, true
+	}
+	if link, text := post.link(); link != "" {
+		return FeedItem{
+			Title:   text,
+			Url:     link,
+			Created: post.Record.CreatedAt,
+		}, true
+	}
+	return postItem(post.Record.Text, post.Record.CreatedAt)
+}
+
+// parseHandle returns a Bluesky handle without its leading "@"
+func parseHandle(handle string) (string, error) {
+	m := handleRE.FindStringSubmatch(handle)
+	if m == nil {
+		return "", fmt.Errorf("invalid Bluesky handle %q, want e.g. user.bsky.social", handle)
+	}
+	return m[1], nil
+}
+
+// blueskyReader reads the items linked from the posts of a Bluesky account
+// through the public AppView API. It keeps the latest NumPosts items and
+// only resolves the URLs of the posts above the newest one read before.
+type blueskyReader struct {
+	API             string // e.g. BlueskyAPI
+	Handle          string
+	NumPosts        int
+	Timeout         time.Duration
+	MaxFailureRatio float64 // Share of URL lookups allowed to fail
+
+	mu       sync.Mutex
+	newest   string // URI of the newest post read
+	resolved resolvedItems
+}
+
+func newBlueskyReader(api string, config Config) (*blueskyReader, error) {
+	handle, err := parseHandle(config.BlueskyHandle)
+	if err != nil {
+		return nil, err
+	}
+	return &blueskyReader{
+		API:             api,
+		Handle:          handle,
+		NumPosts:        config.NumTweets,
+		Timeout:         config.FetchTimeout,
+		MaxFailureRatio: config.MaxFailureRatio,
+	}, nil
+}
+
+// getAuthorFeed returns the latest NumPosts posts of the account, without
+// replies and reposts
+func (r *blueskyReader) getAuthorFeed(ctx context.Context) ([]BlueskyPost, error) {
+	var feed blueskyFeed
+
+	limit := r.NumPosts
+	if limit > BlueskyMaxPosts {
+		limit = BlueskyMaxPosts
+	}
+	params := url.Values{
+		"actor":  {r.Handle},
+		"limit":  {strconv.Itoa(limit)},
+		"filter": {"posts_no_replies"},
+	}
+	u := r.API + BlueskyFeedPath + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{
+		Timeout: r.Timeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("%s: %v", u, err)
+	}
+
+	posts := make([]BlueskyPost, 0, len(feed.Feed))
+	for _, entry := range feed.Feed {
+		if entry.Reason == nil {
+			posts = append(posts, entry.Post)
+		}
+	}
+	return posts, nil
+}
+
+// newPosts returns the posts above the newest post read before, or all of
+// them if it is not among them, and remembers the newest post
+func (r *blueskyReader) newPosts(posts []BlueskyPost) []BlueskyPost {
+	for i, post := range posts {
+		if post.URI == r.newest {
+			posts = posts[:i]
+			break
+		}
+	}
+	if len(posts) > 0 {
+		r.newest = posts[0].URI
+	}
+	return posts
+}
+
+func (r *blueskyReader) readItems(ctx context.Context) ([]FeedItem, []feedkit.ItemError, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := time.Now()
+	posts, err := r.getAuthorFeed(ctx)
+	feedkit.ObserveUpstream(SourceName, "getAuthorFeed", start, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	posts = r.newPosts(posts)
+	feedItems := make([]FeedItem, 0, len(posts))
+	for _, post := range posts {
+		if item, ok := blueskyItem(post); ok {
+			item.PostID = post.URI
+			feedItems = append(feedItems, item)
+		}
+	}
+	return r.resolved.add(ctx, feedItems, r.NumPosts, r.Timeout, r.MaxFailureRatio)
+}
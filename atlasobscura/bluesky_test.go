@@ -0,0 +1,166 @@
+package atlasobscura
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/stretchr/testify/assert"
+)
+
+// blueskyServer is a mock of the getAuthorFeed endpoint of the AppView,
+// serving testdata/bluesky/author_feed.json with links to the pages it
+// also serves
+type blueskyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []url.Values
+	pages    int // Reads of the linked pages
+}
+
+func newBlueskyServer(t *testing.T) *blueskyServer {
+	feed, err := ioutil.ReadFile("testdata/bluesky/author_feed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &blueskyServer{}
+	srv.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != BlueskyFeedPath {
+				// Linked pages
+				srv.mu.Lock()
+				srv.pages++
+				srv.mu.Unlock()
+				w.Header().Set("Content-Type", "text/html")
+				return
+			}
+			srv.mu.Lock()
+			srv.requests = append(srv.requests, r.URL.Query())
+			srv.mu.Unlock()
+			if r.URL.Query().Get("actor") != "atlasobscura.com" {
+				http.Error(w, `{"error":"InvalidRequest","message":"Profile not found"}`,
+					http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(strings.ReplaceAll(string(feed), "{{server}}", srv.URL)))
+		}))
+	return srv
+}
+
+func TestParseHandle(t *testing.T) {
+	handle, err := parseHandle("@atlasobscura.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "atlasobscura.com", handle)
+
+	_, err = parseHandle("atlasobscura.bsky.social")
+	assert.Nil(t, err)
+	_, err = parseHandle("atlasobscura")
+	assert.NotNil(t, err)
+	_, err = parseHandle("https://bsky.app/profile/atlasobscura.com")
+	assert.NotNil(t, err)
+}
+
+func TestImageType(t *testing.T) {
+	assert.Equal(t, "image/jpeg", imageType("https://cdn.bsky.app/img/feed_thumbnail/plain/did:plc:abc/bafkrei@jpeg"))
+	assert.Equal(t, "image/png", imageType("https://img.atlasobscura.com/hero.PNG?w=800"))
+	assert.Equal(t, "image/webp", imageType("https://cdn.bsky.app/img/plain/bafkrei@webp"))
+	assert.Equal(t, "image/jpeg", imageType("https://img.atlasobscura.com/hero"))
+}
+
+func TestBlueskyReader(t *testing.T) {
+	ctx := context.Background()
+	srv := newBlueskyServer(t)
+	defer srv.Close()
+
+	config := DefaultConfig()
+	config.Reader = ReaderBluesky
+	config.BlueskyHandle = "@atlasobscura.com"
+	reader, err := newBlueskyReader(srv.URL, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, failures, err := reader.readItems(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, failures)
+	if assert.Len(t, items, 3) {
+		// Link cards give the title, summary and image
+		assert.Equal(t, FeedItem{
+			Title:   "The Bathroom Ghosts of Japan",
+			Url:     srv.URL + "/articles/japans-bathroom-ghosts",
+			Created: time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC),
+			Summary: "There are several to keep track of, some scarier than others.",
+			Image:   "https://cdn.bsky.app/img/feed_thumbnail/plain/did:plc:abc/bafkrei@jpeg",
+			PostID:  "at://did:plc:abc/app.bsky.feed.post/3k3",
+		}, items[0])
+		// Link facets give the full URL of shortened links
+		assert.Equal(t, "Café tiny horse today", items[1].Title)
+		assert.Equal(t, srv.URL+"/places/worlds-smallest-dala-horse", items[1].Url)
+		// Posts without facets fall back to the URL in the text
+		assert.Equal(t, "Searching for the Lost City of Z", items[2].Title)
+		assert.Equal(t, srv.URL+"/articles/lost-city-of-z", items[2].Url)
+	}
+	if assert.Len(t, srv.requests, 1) {
+		assert.Equal(t, "atlasobscura.com", srv.requests[0].Get("actor"))
+		assert.Equal(t, "20", srv.requests[0].Get("limit"))
+		assert.Equal(t, "posts_no_replies", srv.requests[0].Get("filter"))
+	}
+
+	t.Run("NoNewPosts", func(t *testing.T) {
+		srv.mu.Lock()
+		pages := srv.pages
+		srv.mu.Unlock()
+
+		again, _, err := reader.readItems(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, items, again)
+		// The URLs of the posts read before are not resolved again
+		srv.mu.Lock()
+		assert.Equal(t, pages, srv.pages)
+		srv.mu.Unlock()
+	})
+
+	t.Run("UnknownHandle", func(t *testing.T) {
+		reader := &blueskyReader{API: srv.URL, Handle: "nobody.bsky.social", NumPosts: 20, Timeout: Timeout}
+		_, _, err := reader.readItems(ctx)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Feed", func(t *testing.T) {
+		source := &Source{reader: reader, Config: config}
+		snapshot, err := feedkit.NewFeed("/", source, feedkit.NewFeedConfig()).Snapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Contains(t, string(snapshot.Bodies[feedkit.FormatAtom].Data),
+			"<subtitle>"+BskyDescription+"</subtitle>")
+		assert.Contains(t, string(snapshot.Bodies[feedkit.FormatRSS].Data),
+			`<enclosure url="https://cdn.bsky.app/img/feed_thumbnail/plain/did:plc:abc/bafkrei@jpeg" length="0" type="image/jpeg"></enclosure>`)
+	})
+}
+
+func TestBlueskyNewPosts(t *testing.T) {
+	posts := func(uris ...string) []BlueskyPost {
+		out := make([]BlueskyPost, len(uris))
+		for i, uri := range uris {
+			out[i].URI = uri
+		}
+		return out
+	}
+	reader := &blueskyReader{}
+	assert.Equal(t, posts("b", "a"), reader.newPosts(posts("b", "a")))
+	assert.Equal(t, posts("d", "c"), reader.newPosts(posts("d", "c", "b", "a")))
+	assert.Empty(t, reader.newPosts(posts("d", "c", "b")))
+	// All posts are new once the newest post read is gone, e.g. deleted
+	assert.Equal(t, posts("e", "c"), reader.newPosts(posts("e", "c")))
+	assert.Equal(t, "e", reader.newest)
+}
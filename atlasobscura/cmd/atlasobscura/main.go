@@ -0,0 +1,68 @@
+// Command atlasobscura serves a feed of the new articles and places of
+// Atlas Obscura, read from the site or from its social accounts, and the
+// feeds of other social accounts.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"duh-uh.com/app/twitterrss/atlasobscura"
+)
+
+type config struct {
+	Server       feedkit.ServerConfig `yaml:"server"`
+	AtlasObscura atlasobscura.Config  `yaml:"atlasobscura"`
+}
+
+func (c *config) Flags(fs *flag.FlagSet, prefix string) {
+	c.Server.Flags(fs, prefix)
+	c.AtlasObscura.Flags(fs, prefix+"atlasobscura.")
+}
+
+func (c *config) Validate(prefix string) []string {
+	return append(c.Server.Validate(prefix), c.AtlasObscura.Validate(prefix+"atlasobscura.")...)
+}
+
+func run() error {
+	cfg := &config{
+		Server:       feedkit.DefaultServerConfig(),
+		AtlasObscura: atlasobscura.DefaultConfig(),
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rewrite" {
+		return feedkit.RunRewrite(os.Stdout, "atlasobscura", os.Args[2:], cfg, &cfg.Server)
+	}
+	printConfig, err := feedkit.LoadConfig("atlasobscura", os.Args[1:], cfg)
+	if err != nil {
+		return err
+	}
+	if printConfig {
+		return feedkit.PrintConfig(os.Stdout, cfg)
+	}
+
+	store, err := feedkit.OpenStore(cfg.Server.StorePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	server := feedkit.NewServer(cfg.Server)
+	if err := atlasobscura.Mount(server, "/", cfg.AtlasObscura, store); err != nil {
+		return err
+	}
+
+	ctx, stop := feedkit.SignalContext(context.Background())
+	defer stop()
+	return server.Run(ctx)
+}
+
+func main() {
+	// Fail from run, so that the store is closed before exiting
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,166 @@
+package atlasobscura
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Readers of the feed items
+const (
+	ReaderSite     = "site"     // Pages of atlasobscura.com
+	ReaderTwitter  = "twitter"  // Links tweeted by ScreenName
+	ReaderMastodon = "mastodon" // Links posted by MastodonAccount
+	ReaderBluesky  = "bluesky"  // Links posted by BlueskyHandle
+)
+
+// Config tunes the pages or posts read and the refreshes of the feed, and
+// lists the social accounts served as feeds of their own
+type Config struct {
+	Reader             string        `yaml:"reader"`
+	NumItems           int           `yaml:"num_items"`
+	ScreenName         string        `yaml:"screen_name"`
+	NumTweets          int           `yaml:"num_tweets"`
+	MastodonAccount    string        `yaml:"mastodon_account"`
+	BlueskyHandle      string        `yaml:"bluesky_handle"`
+	Accounts           accountList   `yaml:"accounts"`
+	MastodonInstances  nameList      `yaml:"mastodon_instances"`
+	TwitterScreenNames nameList      `yaml:"twitter_screen_names"`
+	FetchTimeout       time.Duration `yaml:"fetch_timeout"`
+	RefreshInterval    time.Duration `yaml:"refresh_interval"`
+	HistorySize        int           `yaml:"history_size"`
+	HistoryAge         time.Duration `yaml:"history_age"`
+	MaxFailureRatio    float64       `yaml:"max_failure_ratio"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Reader:            ReaderSite,
+		NumItems:          NumItems,
+		ScreenName:        ScreenName,
+		NumTweets:         NumTweets,
+		MastodonInstances: nameList{MastodonInstance},
+		FetchTimeout:      Timeout,
+		RefreshInterval:   CacheInterval,
+		HistorySize:       HistorySize,
+		HistoryAge:        HistoryAge,
+		MaxFailureRatio:   MaxFailureRatio,
+	}
+}
+
+func (c *Config) historyLimits() historyLimits {
+	return historyLimits{Size: c.HistorySize, Age: c.HistoryAge}
+}
+
+func (c *Config) Flags(fs *flag.FlagSet, prefix string) {
+	fs.StringVar(&c.Reader, prefix+"reader", c.Reader,
+		"Where feed items are read from: site, twitter, mastodon or bluesky")
+	fs.IntVar(&c.NumItems, prefix+"num-items", c.NumItems,
+		"Number of pages read from each listing of the site")
+	fs.StringVar(&c.ScreenName, prefix+"screen-name", c.ScreenName,
+		"Twitter user whose tweets are read")
+	fs.IntVar(&c.NumTweets, prefix+"num-tweets", c.NumTweets,
+		"Number of tweets, Mastodon or Bluesky posts read on every refresh")
+	fs.StringVar(&c.MastodonAccount, prefix+"mastodon-account", c.MastodonAccount,
+		"Mastodon account whose posts are read, e.g. @user@mastodon.social")
+	fs.StringVar(&c.BlueskyHandle, prefix+"bluesky-handle", c.BlueskyHandle,
+		"Bluesky handle whose posts are read, e.g. user.bsky.social")
+	fs.Var(&c.Accounts, prefix+"accounts",
+		"Comma-separated list of social accounts served on /social/, e.g. bluesky/user.bsky.social")
+	fs.Var(&c.MastodonInstances, prefix+"mastodon-instances",
+		"Comma-separated list of Mastodon instances whose accounts are served on request")
+	fs.Var(&c.TwitterScreenNames, prefix+"twitter-screen-names",
+		"Comma-separated list of Twitter screen names served on request")
+	fs.DurationVar(&c.FetchTimeout, prefix+"fetch-timeout", c.FetchTimeout,
+		"Timeout of the page reads and URL lookups")
+	fs.DurationVar(&c.RefreshInterval, prefix+"refresh-interval", c.RefreshInterval,
+		"How often the feed is refreshed")
+	fs.IntVar(&c.HistorySize, prefix+"history-size", c.HistorySize,
+		"Max items kept in the feed history, 0 for no limit")
+	fs.DurationVar(&c.HistoryAge, prefix+"history-age", c.HistoryAge,
+		"How long items stay in the feed history, 0 for no limit")
+	fs.Float64Var(&c.MaxFailureRatio, prefix+"max-failure-ratio", c.MaxFailureRatio,
+		"Share of the page reads or URL lookups of a refresh allowed to fail")
+}
+
+func (c *Config) Validate(prefix string) []string {
+	var problems []string
+	switch c.Reader {
+	case ReaderSite:
+		if c.NumItems < 1 {
+			problems = append(problems, prefix+"num-items: must be at least 1")
+		}
+	case ReaderTwitter:
+		if c.ScreenName == "" {
+			problems = append(problems, prefix+"screen-name: must be set")
+		}
+	case ReaderMastodon:
+		if _, _, err := parseAccount(c.MastodonAccount); err != nil {
+			problems = append(problems, prefix+"mastodon-account: "+err.Error())
+		}
+	case ReaderBluesky:
+		if _, err := parseHandle(c.BlueskyHandle); err != nil {
+			problems = append(problems, prefix+"bluesky-handle: "+err.Error())
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("%sreader: must be %s, %s, %s or %s",
+			prefix, ReaderSite, ReaderTwitter, ReaderMastodon, ReaderBluesky))
+	}
+
+	// NumTweets must suit the readers of the feed and of the accounts
+	platforms := map[string]bool{c.Reader: true}
+	for i, account := range c.Accounts {
+		if _, err := account.canonical(); err != nil {
+			problems = append(problems, fmt.Sprintf("%saccounts: #%d: %v", prefix, i+1, err))
+		}
+		if account.RefreshInterval < 0 {
+			problems = append(problems, fmt.Sprintf("%saccounts: #%d: refresh_interval must not be negative", prefix, i+1))
+		}
+		platforms[account.Platform] = true
+	}
+	if len(c.TwitterScreenNames) > 0 {
+		platforms[ReaderTwitter] = true
+	}
+	for _, instance := range c.MastodonInstances {
+		if !instanceRE.MatchString(instance) {
+			problems = append(problems, fmt.Sprintf("%smastodon-instances: invalid instance %q, want e.g. %s",
+				prefix, instance, MastodonInstance))
+		}
+	}
+	for _, name := range c.TwitterScreenNames {
+		if !screenNameRE.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("%stwitter-screen-names: invalid Twitter screen name %q",
+				prefix, name))
+		}
+	}
+	switch {
+	case platforms[ReaderTwitter] && (c.NumTweets < 5 || c.NumTweets > 100):
+		// Limits of the Twitter user timeline API
+		problems = append(problems, prefix+"num-tweets: must be between 5 and 100")
+	case platforms[ReaderBluesky] && (c.NumTweets < 1 || c.NumTweets > BlueskyMaxPosts):
+		// Limits of getAuthorFeed
+		problems = append(problems, fmt.Sprintf("%snum-tweets: must be between 1 and %d",
+			prefix, BlueskyMaxPosts))
+	case platforms[ReaderMastodon] && c.NumTweets < 1:
+		problems = append(problems, prefix+"num-tweets: must be at least 1")
+	}
+	if c.FetchTimeout <= 0 {
+		problems = append(problems, prefix+"fetch-timeout: must be positive")
+	}
+	if c.RefreshInterval <= 0 {
+		problems = append(problems, prefix+"refresh-interval: must be positive")
+	}
+	if c.HistorySize < 0 {
+		problems = append(problems, prefix+"history-size: must not be negative")
+	}
+	if c.HistoryAge < 0 {
+		problems = append(problems, prefix+"history-age: must not be negative")
+	}
+	if c.HistorySize == 0 && c.HistoryAge == 0 {
+		problems = append(problems, prefix+"history-size, history-age: one of them must be set")
+	}
+	if c.MaxFailureRatio < 0 || c.MaxFailureRatio > 1 {
+		problems = append(problems, prefix+"max-failure-ratio: must be between 0 and 1")
+	}
+	return problems
+}
@@ -0,0 +1,88 @@
+package atlasobscura
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	config := DefaultConfig()
+	assert.Empty(t, config.Validate(""))
+
+	config.NumItems = 0
+	assert.Equal(t, []string{"num-items: must be at least 1"}, config.Validate(""))
+
+	config = DefaultConfig()
+	config.Reader = ReaderTwitter
+	assert.Empty(t, config.Validate(""))
+	config.ScreenName = ""
+	config.NumTweets = 500
+	assert.Equal(t, []string{
+		"screen-name: must be set",
+		"num-tweets: must be between 5 and 100",
+	}, config.Validate(""))
+
+	config = DefaultConfig()
+	config.Reader = ReaderMastodon
+	config.MastodonAccount = "@atlasobscura@mastodon.social"
+	assert.Empty(t, config.Validate(""))
+	config.MastodonAccount = "atlasobscura"
+	assert.Equal(t, []string{
+		`mastodon-account: invalid Mastodon account "atlasobscura", want @user@instance`,
+	}, config.Validate(""))
+
+	config = DefaultConfig()
+	config.Reader = ReaderBluesky
+	config.BlueskyHandle = "@atlasobscura.com"
+	assert.Empty(t, config.Validate(""))
+	config.BlueskyHandle = "atlasobscura"
+	config.NumTweets = 500
+	assert.Equal(t, []string{
+		`bluesky-handle: invalid Bluesky handle "atlasobscura", want e.g. user.bsky.social`,
+		"num-tweets: must be between 1 and 100",
+	}, config.Validate(""))
+
+	config.Reader = "rss"
+	assert.Equal(t, []string{"reader: must be site, twitter, mastodon or bluesky"}, config.Validate(""))
+
+	config = DefaultConfig()
+	config.Accounts = accountList{
+		{Platform: ReaderBluesky, Handle: "atlasobscura.com"},
+		{Platform: ReaderTwitter, Handle: "atlasobscura", RefreshInterval: -time.Minute},
+		{Platform: "myspace", Handle: "atlasobscura"},
+	}
+	config.NumTweets = 1
+	assert.Equal(t, []string{
+		"accounts: #2: refresh_interval must not be negative",
+		"accounts: #3: unknown platform \"myspace\", want twitter, mastodon or bluesky",
+		"num-tweets: must be between 5 and 100",
+	}, config.Validate(""))
+
+	config = DefaultConfig()
+	config.MastodonInstances = nameList{"hachyderm.io", "https://mastodon.social"}
+	assert.Equal(t, []string{
+		`mastodon-instances: invalid instance "https://mastodon.social", want e.g. mastodon.social`,
+	}, config.Validate(""))
+
+	config = DefaultConfig()
+	config.TwitterScreenNames = nameList{"@atlasobscura", "atlas obscura"}
+	config.NumTweets = 1
+	assert.Equal(t, []string{
+		`twitter-screen-names: invalid Twitter screen name "atlas obscura"`,
+		"num-tweets: must be between 5 and 100",
+	}, config.Validate(""))
+
+	config = DefaultConfig()
+	config.HistoryAge = 0
+	assert.Empty(t, config.Validate(""))
+	config.HistorySize = 0
+	assert.Equal(t, []string{"history-size, history-age: one of them must be set"}, config.Validate(""))
+	config.HistorySize = -1
+	assert.Equal(t, []string{"history-size: must not be negative"}, config.Validate(""))
+
+	config = DefaultConfig()
+	config.MaxFailureRatio = -0.1
+	assert.Equal(t, []string{"max-failure-ratio: must be between 0 and 1"}, config.Validate(""))
+}
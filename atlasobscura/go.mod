@@ -3,10 +3,9 @@ module duh-uh.com/app/twitterrss/atlasobscura
 go 1.15
 
 require (
+	duh-uh.com/app/rss-feeds/feedkit v0.0.0
 	github.com/g8rswimmer/go-twitter v1.1.4
-	github.com/gorilla/feeds v1.1.1
-	github.com/kr/pretty v0.1.0 // indirect
-	github.com/patrickmn/go-cache v2.1.0+incompatible
-	github.com/stretchr/testify v1.4.0
-	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	github.com/stretchr/testify v1.7.0
 )
+
+replace duh-uh.com/app/rss-feeds/feedkit => ../feedkit
@@ -0,0 +1,90 @@
+package atlasobscura
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+)
+
+// Store bucket holding the feed items seen so far, keyed by URL. The feeds
+// of social accounts keep theirs in buckets named after their path below
+// it, e.g. "items/twitter/atlasobscura".
+const itemsBucket = "items"
+
+// StoredItem is a feed item along with the time it was first seen
+type StoredItem struct {
+	FeedItem
+	FirstSeen time.Time
+}
+
+// historyLimits bounds the items kept in the feed history. Zero disables
+// a bound.
+type historyLimits struct {
+	Size int           // Max items in the feed
+	Age  time.Duration // How long items stay in the feed
+}
+
+// itemHistory saves the feed items fetched in bucket and returns them along
+// with the items seen in earlier refreshes, newest first. Items stay in the
+// feed for limits.Age, up to a maximum of limits.Size items.
+func itemHistory(store feedkit.Store, bucket string, items []FeedItem, limits historyLimits, now time.Time) ([]FeedItem, error) {
+	stored := make(map[string]StoredItem)
+	err := store.ForEach(bucket, func(key string, data []byte) error {
+		var item StoredItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+		stored[key] = item
+		return nil
+	})
+	if err != nil {
+		return items, err
+	}
+
+	updated := make(map[string]interface{})
+	for _, item := range items {
+		firstSeen := now
+		if previous, found := stored[item.Url]; found {
+			if previous.FeedItem == item {
+				continue
+			}
+			firstSeen = previous.FirstSeen
+		}
+		stored[item.Url] = StoredItem{item, firstSeen}
+		updated[item.Url] = stored[item.Url]
+	}
+
+	history := make([]StoredItem, 0, len(stored))
+	expired := make([]string, 0)
+	for key, item := range stored {
+		if limits.Age > 0 && now.Sub(item.FirstSeen) > limits.Age {
+			expired = append(expired, key)
+			continue
+		}
+		history = append(history, item)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Created.After(history[j].Created)
+	})
+	if limits.Size > 0 && len(history) > limits.Size {
+		for _, item := range history[limits.Size:] {
+			expired = append(expired, item.Url)
+		}
+		history = history[:limits.Size]
+	}
+
+	if err := store.Put(bucket, updated); err != nil {
+		return items, err
+	}
+	if err := store.Delete(bucket, expired...); err != nil {
+		return items, err
+	}
+
+	feedItems := make([]FeedItem, 0, len(history))
+	for _, item := range history {
+		feedItems = append(feedItems, item.FeedItem)
+	}
+	return feedItems, nil
+}
@@ -0,0 +1,78 @@
+package atlasobscura
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemHistory(t *testing.T) {
+	store, err := feedkit.NewBoltStore(filepath.Join(t.TempDir(), "feed.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	limits := historyLimits{Size: HistorySize, Age: HistoryAge}
+	now := time.Date(2021, time.May, 2, 15, 0, 0, 0, time.UTC)
+	horse := FeedItem{
+		Title:   "This Dalecarlian horse is about the size of a pinhead.",
+		Url:     "https://www.atlasobscura.com/places/worlds-smallest-dala-horse",
+		Created: now.Add(-time.Hour),
+	}
+	ghosts := FeedItem{
+		Title:   "There are several to keep track of, some scarier than others.",
+		Url:     "https://www.atlasobscura.com/articles/japans-bathroom-ghosts",
+		Created: now.Add(-30 * time.Minute),
+	}
+
+	items, err := itemHistory(store, itemsBucket, []FeedItem{horse}, limits, now)
+	assert.Nil(t, err)
+	assert.Equal(t, []FeedItem{horse}, items)
+
+	// Items no longer returned upstream stay in the feed
+	items, err = itemHistory(store, itemsBucket, []FeedItem{ghosts}, limits, now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, []FeedItem{ghosts, horse}, items)
+
+	items, err = itemHistory(store, itemsBucket, nil, limits, now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, []FeedItem{ghosts, horse}, items)
+
+	// ... until they are older than HistoryAge
+	items, err = itemHistory(store, itemsBucket, nil, limits, now.Add(HistoryAge+time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, []FeedItem{ghosts}, items)
+
+	t.Run("Limits", func(t *testing.T) {
+		bucket := "items/limits"
+		items, err := itemHistory(store, bucket, []FeedItem{horse, ghosts}, historyLimits{Size: 1}, now)
+		assert.Nil(t, err)
+		assert.Equal(t, []FeedItem{ghosts}, items)
+
+		// Without an age limit items stay until they are pushed out
+		hours := make([]FeedItem, 3)
+		for i := range hours {
+			hours[i] = FeedItem{
+				Title:   fmt.Sprintf("Item %d", i),
+				Url:     fmt.Sprintf("https://www.atlasobscura.com/articles/%d", i),
+				Created: now.Add(time.Duration(i) * time.Hour),
+			}
+		}
+		limits := historyLimits{Size: 3}
+		_, err = itemHistory(store, bucket, hours[:2], limits, now)
+		assert.Nil(t, err)
+		items, err = itemHistory(store, bucket, hours[2:], limits, now.Add(10*HistoryAge))
+		assert.Nil(t, err)
+		assert.Equal(t, []FeedItem{hours[2], hours[1], hours[0]}, items)
+
+		// Without a size limit items stay until they are too old
+		items, err = itemHistory(store, bucket, []FeedItem{horse}, historyLimits{Age: time.Hour}, now.Add(10*HistoryAge))
+		assert.Nil(t, err)
+		assert.Equal(t, []FeedItem{hours[2], horse}, items)
+	})
+}
@@ -0,0 +1,215 @@
+package atlasobscura
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+)
+
+const (
+	MastodonInstance = "mastodon.social" // Default MastodonInstances
+	MastodonPageSize = 40                // Max statuses per page of the Mastodon API
+	// Path of the statuses of an account below the instance URL
+	MastodonStatusesPath = "/api/v1/accounts/%s/statuses"
+	MastodonLookupPath   = "/api/v1/accounts/lookup"
+)
+
+var (
+	accountRE   = regexp.MustCompile(`^@?([\w.-]+)@([\w.-]+\.[a-z]+)$`)
+	instanceRE  = regexp.MustCompile(`^[\w.-]+\.[a-z]+$`)
+	paragraphRE = regexp.MustCompile(`(?i)</p>\s*<p>|<br\s*/?>`)
+	htmlTagRE   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Status is a post of the Mastodon API
+type Status struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   string    `json:"content"` // HTML
+	URL       string    `json:"url"`
+}
+
+// parseAccount splits a Mastodon account, e.g. "@user@mastodon.social",
+// into the username and the URL of the instance
+func parseAccount(account string) (string, string, error) {
+	m := accountRE.FindStringSubmatch(account)
+	if m == nil {
+		return "", "", fmt.Errorf("invalid Mastodon account %q, want @user@instance", account)
+	}
+	return m[1], "https://" + m[2], nil
+}
+
+// statusText converts the HTML content of a status to text. Links keep
+// their full URL, which Mastodon splits into visible and invisible parts.
+func statusText(content string) string {
+	text := paragraphRE.ReplaceAllString(content, " ")
+	text = html.UnescapeString(htmlTagRE.ReplaceAllString(text, ""))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// mastodonReader reads the items linked from the public statuses of a
+// Mastodon account. It keeps the latest NumPosts items and only asks for
+// the statuses newer than the ones read before on later refreshes, so that
+// only their URLs are resolved.
+type mastodonReader struct {
+	Instance        string // e.g. "https://mastodon.social"
+	Username        string
+	NumPosts        int
+	Timeout         time.Duration
+	MaxFailureRatio float64           // Share of URL lookups allowed to fail
+	Transport       http.RoundTripper // nil for http.DefaultTransport
+
+	mu        sync.Mutex
+	accountID string
+	sinceID   string // ID of the newest status read
+	resolved  resolvedItems
+}
+
+func newMastodonReader(config Config) (*mastodonReader, error) {
+	username, instance, err := parseAccount(config.MastodonAccount)
+	if err != nil {
+		return nil, err
+	}
+	return &mastodonReader{
+		Instance:        instance,
+		Username:        username,
+		NumPosts:        config.NumTweets,
+		Timeout:         config.FetchTimeout,
+		MaxFailureRatio: config.MaxFailureRatio,
+	}, nil
+}
+
+func (r *mastodonReader) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	u := r.Instance + path + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	client := http.Client{
+		Timeout:   r.Timeout,
+		Transport: r.Transport,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%s: %v", u, err)
+	}
+	return nil
+}
+
+// lookupAccount returns the ID of the account, which is only looked up
+// once
+func (r *mastodonReader) lookupAccount(ctx context.Context) (string, error) {
+	if r.accountID != "" {
+		return r.accountID, nil
+	}
+	var account struct {
+		ID string `json:"id"`
+	}
+	start := time.Now()
+	err := r.get(ctx, MastodonLookupPath, url.Values{"acct": {r.Username}}, &account)
+	feedkit.ObserveUpstream(SourceName, "lookupAccount", start, err)
+	if err != nil {
+		return "", err
+	}
+	if account.ID == "" {
+		return "", fmt.Errorf("no Mastodon account %s on %s", r.Username, r.Instance)
+	}
+	r.accountID = account.ID
+	return r.accountID, nil
+}
+
+// getStatuses returns the statuses newer than sinceID, newest first, up to
+// NumPosts. Pages are followed with max_id.
+func (r *mastodonReader) getStatuses(ctx context.Context, accountID string, sinceID string) ([]Status, error) {
+	statuses := make([]Status, 0)
+	maxID := ""
+	for len(statuses) < r.NumPosts {
+		limit := r.NumPosts - len(statuses)
+		if limit > MastodonPageSize {
+			limit = MastodonPageSize
+		}
+		params := url.Values{
+			"limit":           {strconv.Itoa(limit)},
+			"exclude_replies": {"true"},
+			"exclude_reblogs": {"true"},
+		}
+		if sinceID != "" {
+			params.Set("since_id", sinceID)
+		}
+		if maxID != "" {
+			params.Set("max_id", maxID)
+		}
+
+		var page []Status
+		start := time.Now()
+		err := r.get(ctx, fmt.Sprintf(MastodonStatusesPath, url.PathEscape(accountID)), params, &page)
+		feedkit.ObserveUpstream(SourceName, "getStatuses", start, err)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, page...)
+		if len(page) < limit {
+			break
+		}
+		maxID = page[len(page)-1].ID
+	}
+	return statuses, nil
+}
+
+// readStatuses returns the statuses posted since the last refresh, newest
+// first, up to NumPosts
+func (r *mastodonReader) readStatuses(ctx context.Context) ([]Status, error) {
+	accountID, err := r.lookupAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := r.getStatuses(ctx, accountID, r.sinceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(newer) > 0 {
+		r.sinceID = newer[0].ID
+	}
+	return newer, nil
+}
+
+func (r *mastodonReader) readItems(ctx context.Context) ([]FeedItem, []feedkit.ItemError, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses, err := r.readStatuses(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	feedItems := make([]FeedItem, 0, len(statuses))
+	for _, status := range statuses {
+		if item, ok := postItem(statusText(status.Content), status.CreatedAt); ok {
+			item.PostID = status.ID
+			feedItems = append(feedItems, item)
+		}
+	}
+	return r.resolved.add(ctx, feedItems, r.NumPosts, r.Timeout, r.MaxFailureRatio)
+}
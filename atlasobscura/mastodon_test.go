@@ -0,0 +1,189 @@
+package atlasobscura
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mastodonServer is a mock of the account endpoints of a Mastodon
+// instance, which also serves the pages the statuses link to
+type mastodonServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []Status // Newest first
+	lookups  int
+	requests []url.Values
+	pages    map[string]int // Reads of the linked pages
+}
+
+func newMastodonServer(t *testing.T) *mastodonServer {
+	srv := &mastodonServer{pages: make(map[string]int)}
+	srv.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case MastodonLookupPath:
+				srv.lookups++
+				if r.URL.Query().Get("acct") != "atlasobscura" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(`{"id": "42", "username": "atlasobscura"}`))
+			case fmt.Sprintf(MastodonStatusesPath, "42"):
+				params := r.URL.Query()
+				srv.requests = append(srv.requests, params)
+				json.NewEncoder(w).Encode(srv.page(params))
+			default:
+				// Linked pages
+				srv.pages[r.URL.Path]++
+				w.Header().Set("Content-Type", "text/html")
+			}
+		}))
+	return srv
+}
+
+// page returns the statuses selected by the pagination parameters
+func (s *mastodonServer) page(params url.Values) []Status {
+	id := func(name string) int {
+		n, _ := strconv.Atoi(params.Get(name))
+		return n
+	}
+	limit := id("limit")
+	page := make([]Status, 0)
+	for _, status := range s.statuses {
+		n, _ := strconv.Atoi(status.ID)
+		if (params.Get("max_id") != "" && n >= id("max_id")) || n <= id("since_id") {
+			continue
+		}
+		if len(page) < limit {
+			page = append(page, status)
+		}
+	}
+	return page
+}
+
+// Post adds a status linking to path on the server
+func (s *mastodonServer) Post(id int, text string, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content := fmt.Sprintf(`<p>%s <a href="%s%s" rel="nofollow noopener" target="_blank">`+
+		`<span class="invisible">http://</span><span class="">%s%s</span>`+
+		`<span class="invisible"></span></a></p>`, text, s.URL, path, s.URL[len("http://"):], path)
+	s.statuses = append([]Status{{
+		ID:        strconv.Itoa(id),
+		CreatedAt: time.Date(2023, time.January, 1, 0, id, 0, 0, time.UTC),
+		Content:   content,
+	}}, s.statuses...)
+}
+
+func TestParseAccount(t *testing.T) {
+	username, instance, err := parseAccount("@atlasobscura@mastodon.social")
+	assert.Nil(t, err)
+	assert.Equal(t, "atlasobscura", username)
+	assert.Equal(t, "https://mastodon.social", instance)
+
+	_, _, err = parseAccount("atlasobscura@mastodon.social")
+	assert.Nil(t, err)
+	_, _, err = parseAccount("https://mastodon.social/@atlasobscura")
+	assert.NotNil(t, err)
+}
+
+func TestStatusText(t *testing.T) {
+	content := `<p>The bathroom ghosts of <a href="https://mastodon.social/tags/japan" class="mention hashtag" rel="tag">#<span>Japan</span></a> &amp; more ` +
+		`<a href="https://www.atlasobscura.com/articles/japans-bathroom-ghosts" rel="nofollow noopener" target="_blank">` +
+		`<span class="invisible">https://www.</span><span class="ellipsis">atlasobscura.com/articles/japan</span>` +
+		`<span class="invisible">s-bathroom-ghosts</span></a></p><p>Via <span class="h-card"><a href="https://mastodon.social/@bob" class="u-url mention">@<span>bob</span></a></span></p>`
+	text := statusText(content)
+	assert.Equal(t, "The bathroom ghosts of #Japan & more https://www.atlasobscura.com/articles/japans-bathroom-ghosts Via @bob", text)
+
+	item, ok := postItem(text, time.Time{})
+	assert.True(t, ok)
+	assert.Equal(t, "The bathroom ghosts of #Japan & more", item.Title)
+	assert.Equal(t, "https://www.atlasobscura.com/articles/japans-bathroom-ghosts", item.Url)
+}
+
+func TestMastodonReader(t *testing.T) {
+	ctx := context.Background()
+	srv := newMastodonServer(t)
+	defer srv.Close()
+	for id := 1; id <= 5; id++ {
+		srv.Post(id, fmt.Sprintf("Post %d", id), fmt.Sprintf("/articles/%d?utm_source=mastodon", id))
+	}
+	srv.Post(6, "No link", "")
+	srv.statuses[0].Content = "<p>No link</p>"
+
+	reader := &mastodonReader{
+		Instance: srv.URL,
+		Username: "atlasobscura",
+		NumPosts: 4,
+		Timeout:  Timeout,
+	}
+	items, failures, err := reader.readItems(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, failures)
+	if assert.Len(t, items, 3) {
+		assert.Equal(t, FeedItem{
+			Title:   "Post 5",
+			Url:     srv.URL + "/articles/5",
+			Created: time.Date(2023, time.January, 1, 0, 5, 0, 0, time.UTC),
+			PostID:  "5",
+		}, items[0])
+		assert.Equal(t, "Post 3", items[2].Title)
+	}
+
+	t.Run("SinceID", func(t *testing.T) {
+		srv.Post(7, "Post 7", "/articles/7")
+		items, _, err := reader.readItems(ctx)
+		assert.Nil(t, err)
+		// The items read before are kept up to NumPosts
+		if assert.Len(t, items, 4) {
+			assert.Equal(t, "Post 7", items[0].Title)
+			assert.Equal(t, "Post 3", items[3].Title)
+		}
+		// Only the URL of the new status is resolved
+		srv.mu.Lock()
+		assert.Equal(t, 1, srv.pages["/articles/7"])
+		assert.Equal(t, 1, srv.pages["/articles/5"])
+		srv.mu.Unlock()
+		assert.Equal(t, 1, srv.lookups)
+		last := srv.requests[len(srv.requests)-1]
+		assert.Equal(t, "6", last.Get("since_id"))
+		assert.Equal(t, "true", last.Get("exclude_reblogs"))
+	})
+
+	t.Run("MaxID", func(t *testing.T) {
+		for id := 8; id <= 100; id++ {
+			srv.Post(id, fmt.Sprintf("Post %d", id), fmt.Sprintf("/articles/%d", id))
+		}
+		reader.NumPosts = 90
+		srv.requests = nil
+		items, _, err := reader.readItems(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, items, 90)
+		if assert.Len(t, srv.requests, 3) {
+			assert.Equal(t, "", srv.requests[0].Get("max_id"))
+			assert.Equal(t, "61", srv.requests[1].Get("max_id"))
+			assert.Equal(t, "21", srv.requests[2].Get("max_id"))
+			assert.Equal(t, "10", srv.requests[2].Get("limit"))
+		}
+	})
+
+	t.Run("UnknownAccount", func(t *testing.T) {
+		reader := &mastodonReader{Instance: srv.URL, Username: "nobody", NumPosts: 4, Timeout: Timeout}
+		_, _, err := reader.readItems(ctx)
+		assert.NotNil(t, err)
+	})
+}
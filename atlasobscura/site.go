@@ -0,0 +1,306 @@
+package atlasobscura
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+)
+
+const (
+	NumItems     = 20      // Default NumItems
+	MaxPageSize  = 4 << 20 // Bytes read from a page
+	numPageReads = 10      // Concurrent page reads
+)
+
+var (
+	// Links to articles and places on the listing pages, by path
+	pageLinkRE = regexp.MustCompile(`href=["'](?:https://www\.atlasobscura\.com)?(/(?:articles|places)/[a-z0-9-]+)["'?#]`)
+	metaRE     = regexp.MustCompile(`(?i)<meta\s[^>]*>`)
+	attrRE     = regexp.MustCompile(`([\w:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	titleTagRE = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	// Pages without a meta tag of the publish date may have it in their
+	// JSON-LD data
+	datePublishedRE = regexp.MustCompile(`"datePublished"\s*:\s*"([^"]+)"`)
+	titleSuffixRE   = regexp.MustCompile(`\s+[-–|]\s+Atlas Obscura$`)
+)
+
+// Site lists the pages of atlasobscura.com the feed is read from
+type Site struct {
+	Listings []string // Listing pages of the newest articles and places
+}
+
+func DefaultSite() Site {
+	return Site{
+		Listings: []string{
+			FeedURL + "/articles",
+			FeedURL + "/places?sort=recent",
+		},
+	}
+}
+
+// siteReader builds the feed items straight from the article and place
+// pages of the site, found on its listing pages. Pages are read once and
+// kept while they are listed.
+type siteReader struct {
+	Site            Site
+	NumItems        int // Pages read from each listing
+	Timeout         time.Duration
+	MaxFailureRatio float64 // Share of page reads allowed to fail
+
+	mu    sync.Mutex
+	pages map[string]FeedItem // Pages read so far, by URL
+}
+
+func newSiteReader(site Site, config Config) *siteReader {
+	return &siteReader{
+		Site:            site,
+		NumItems:        config.NumItems,
+		Timeout:         config.FetchTimeout,
+		MaxFailureRatio: config.MaxFailureRatio,
+		pages:           make(map[string]FeedItem),
+	}
+}
+
+func getPage(ctx context.Context, pageURL string, timeout time.Duration) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", err
+	}
+	client := http.Client{
+		Timeout: timeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", pageURL, resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxPageSize))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// parseListing returns the URLs of the articles and places linked from a
+// listing page, in the order of the page
+func parseListing(listingURL string, page string) []string {
+	base, err := url.Parse(listingURL)
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	urls := make([]string, 0)
+	for _, m := range pageLinkRE.FindAllStringSubmatch(page, -1) {
+		link, err := base.Parse(m[1])
+		if err != nil || seen[link.String()] {
+			continue
+		}
+		seen[link.String()] = true
+		urls = append(urls, link.String())
+	}
+	return urls
+}
+
+// metaTags returns the contents of the meta tags of a page, by property or
+// name, e.g. "og:title"
+func metaTags(page string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range metaRE.FindAllString(page, -1) {
+		attrs := make(map[string]string)
+		for _, m := range attrRE.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = m[2] + m[3]
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		if _, found := tags[key]; key != "" && !found {
+			tags[key] = html.UnescapeString(attrs["content"])
+		}
+	}
+	return tags
+}
+
+func parseTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05-0700", "2006-01-02"} {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parsePage builds the feed item of an article or place page from its
+// Open Graph tags. Pages without a publish date get seen as their date.
+func parsePage(pageURL string, page string, seen time.Time) (FeedItem, error) {
+	tags := metaTags(page)
+	item := FeedItem{
+		Title:   tags["og:title"],
+		Url:     pageURL,
+		Created: seen,
+		Summary: tags["og:description"],
+		Image:   tags["og:image"],
+	}
+	if item.Title == "" {
+		if m := titleTagRE.FindStringSubmatch(page); m != nil {
+			item.Title = html.UnescapeString(strings.TrimSpace(m[1]))
+		}
+	}
+	item.Title = titleSuffixRE.ReplaceAllString(item.Title, "")
+	if item.Title == "" {
+		return FeedItem{}, fmt.Errorf("%s: no title", pageURL)
+	}
+	if item.Summary == "" {
+		item.Summary = tags["description"]
+	}
+	if canonical := tags["og:url"]; strings.HasPrefix(canonical, FeedURL+"/") {
+		item.Url = canonical
+	}
+
+	published := tags["article:published_time"]
+	if published == "" {
+		if m := datePublishedRE.FindStringSubmatch(page); m != nil {
+			published = m[1]
+		}
+	}
+	if published != "" {
+		created, err := parseTime(published)
+		if err != nil {
+			return FeedItem{}, fmt.Errorf("%s: invalid publish date: %v", pageURL, err)
+		}
+		item.Created = created
+	}
+	return item, nil
+}
+
+// listedPages returns the URLs of the first NumItems pages of every
+// listing, along with the listings which could not be read
+func (r *siteReader) listedPages(ctx context.Context) ([]string, []feedkit.ItemError, error) {
+	urls := make([]string, 0)
+	failures := make([]feedkit.ItemError, 0)
+	seen := make(map[string]bool)
+	for _, listing := range r.Site.Listings {
+		start := time.Now()
+		page, err := getPage(ctx, listing, r.Timeout)
+		feedkit.ObserveUpstream(SourceName, "getListing", start, err)
+		if err != nil {
+			log.Printf("Failed to read listing %s: %v", listing, err)
+			failures = append(failures, feedkit.ItemError{Item: listing, Error: err.Error()})
+			continue
+		}
+		listed := parseListing(listing, page)
+		if len(listed) > r.NumItems {
+			listed = listed[:r.NumItems]
+		}
+		for _, u := range listed {
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	if len(failures) == len(r.Site.Listings) {
+		return nil, failures, fmt.Errorf("failed to read all %d listings", len(failures))
+	}
+	return urls, failures, nil
+}
+
+// readItems returns the items of the pages on the listings, newest first.
+// Only pages which were not read before are read.
+func (r *siteReader) readItems(ctx context.Context) ([]FeedItem, []feedkit.ItemError, error) {
+	urls, failures, err := r.listedPages(ctx)
+	if err != nil {
+		return nil, failures, err
+	}
+
+	r.mu.Lock()
+	pages := make(map[string]FeedItem, len(urls))
+	unread := make([]string, 0)
+	for _, u := range urls {
+		if item, found := r.pages[u]; found {
+			pages[u] = item
+		} else {
+			unread = append(unread, u)
+		}
+	}
+	r.mu.Unlock()
+
+	log.Printf("Reading %d new of %d listed pages", len(unread), len(urls))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	pageFailures := make([]feedkit.ItemError, 0)
+	sem := make(chan struct{}, numPageReads)
+reads:
+	for _, u := range unread {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break reads
+		}
+		wg.Add(1)
+		go func(pageURL string) {
+			defer func() { <-sem; wg.Done() }()
+			start := time.Now()
+			page, err := getPage(ctx, pageURL, r.Timeout)
+			feedkit.ObserveUpstream(SourceName, "getPage", start, err)
+			var item FeedItem
+			if err == nil {
+				item, err = parsePage(pageURL, page, time.Now())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("Failed to read page %s: %v", pageURL, err)
+				pageFailures = append(pageFailures, feedkit.ItemError{Item: pageURL, Error: err.Error()})
+				return
+			}
+			pages[pageURL] = item
+		}(u)
+	}
+	// Reads in flight write to pages, so they are waited for even when the
+	// refresh is cancelled
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, failures, err
+	}
+
+	failures = append(failures, pageFailures...)
+	if feedkit.TooManyFailures(len(pageFailures), len(urls), r.MaxFailureRatio) {
+		return nil, failures, fmt.Errorf("%d of %d page reads failed",
+			len(pageFailures), len(urls))
+	}
+
+	// Pages which are no longer listed are dropped, they stay in the feed
+	// through the item history
+	r.mu.Lock()
+	r.pages = pages
+	r.mu.Unlock()
+
+	items := make([]FeedItem, 0, len(pages))
+	for _, item := range pages {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Created.After(items[j].Created)
+	})
+	return items, failures, nil
+}
@@ -0,0 +1,236 @@
+package atlasobscura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/stretchr/testify/assert"
+)
+
+// siteServer serves the pages in testdata/site, trimmed down from the
+// markup of the site to the head and the links around the cards, e.g.
+// testdata/site/articles/japans-bathroom-ghosts.html for
+// /articles/japans-bathroom-ghosts
+type siteServer struct {
+	*httptest.Server
+	Site Site
+
+	mu    sync.Mutex
+	reads map[string]int
+}
+
+func newSiteServer(t *testing.T) *siteServer {
+	srv := &siteServer{reads: make(map[string]int)}
+	srv.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			srv.mu.Lock()
+			srv.reads[r.URL.Path]++
+			srv.mu.Unlock()
+			page, err := ioutil.ReadFile(filepath.Join("testdata/site", r.URL.Path+".html"))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(page)
+		}))
+	srv.Site = Site{
+		Listings: []string{srv.URL + "/articles", srv.URL + "/places?sort=recent"},
+	}
+	return srv
+}
+
+// Reads returns the number of times a path was requested
+func (s *siteServer) Reads(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads[path]
+}
+
+func readFixture(t *testing.T, path string) string {
+	page, err := ioutil.ReadFile(filepath.Join("testdata/site", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(page)
+}
+
+func TestParseListing(t *testing.T) {
+	urls := parseListing("https://example.com/articles", readFixture(t, "articles.html"))
+	assert.Equal(t, []string{
+		"https://example.com/articles/japans-bathroom-ghosts",
+		"https://example.com/articles/lost-city-of-z",
+		"https://example.com/articles/deleted-story",
+	}, urls)
+}
+
+func TestParsePage(t *testing.T) {
+	seen := time.Date(2021, time.May, 3, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		Name    string
+		Fixture string
+		Want    FeedItem
+	}{
+		{"Article", "articles/japans-bathroom-ghosts.html", FeedItem{
+			Title:   "The Bathroom Ghosts of Japan",
+			Url:     "https://www.atlasobscura.com/articles/japans-bathroom-ghosts",
+			Created: time.Date(2021, time.May, 2, 13, 30, 3, 0, time.UTC),
+			Summary: "There are several to keep track of, some scarier than others & some helpful.",
+			Image:   "https://img.atlasobscura.com/ghosts-hero.jpg",
+		}},
+		{"JSONLD", "articles/lost-city-of-z.html", FeedItem{
+			Title:   "Searching for the Lost City of Z",
+			Url:     "https://www.atlasobscura.com/articles/lost-city-of-z",
+			Created: time.Date(2021, time.May, 1, 12, 0, 0, 0, time.UTC),
+			Summary: "An explorer vanished in the Amazon in 1925.",
+		}},
+		{"Place", "places/worlds-smallest-dala-horse.html", FeedItem{
+			Title:   "World's Smallest Dala Horse – Stockholm, Sweden",
+			Url:     "https://www.atlasobscura.com/places/worlds-smallest-dala-horse",
+			Created: seen,
+			Summary: "This Dalecarlian horse is about the size of a pinhead.",
+			Image:   "https://img.atlasobscura.com/dala-horse.jpg",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			item, err := parsePage("https://example.com/page", readFixture(t, test.Fixture), seen)
+			assert.Nil(t, err)
+			assert.True(t, test.Want.Created.Equal(item.Created), item.Created)
+			item.Created = test.Want.Created
+			assert.Equal(t, test.Want, item)
+		})
+	}
+
+	t.Run("NoTitle", func(t *testing.T) {
+		_, err := parsePage("https://example.com/page", "<html></html>", seen)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSiteReader(t *testing.T) {
+	ctx := context.Background()
+	srv := newSiteServer(t)
+	defer srv.Close()
+	reader := newSiteReader(srv.Site, DefaultConfig())
+
+	items, failures, err := reader.readItems(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, items, 3) {
+		// The place has no publish date, so it was published when read
+		assert.Equal(t, "World's Smallest Dala Horse – Stockholm, Sweden", items[0].Title)
+		assert.Equal(t, "The Bathroom Ghosts of Japan", items[1].Title)
+		assert.Equal(t, "Searching for the Lost City of Z", items[2].Title)
+	}
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, srv.URL+"/articles/deleted-story", failures[0].Item)
+	}
+	assert.Equal(t, 1, srv.Reads("/articles/lost-city-of-z"))
+
+	t.Run("ReadOnce", func(t *testing.T) {
+		again, _, err := reader.readItems(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, items, again)
+		assert.Equal(t, 2, srv.Reads("/articles"))
+		assert.Equal(t, 1, srv.Reads("/articles/japans-bathroom-ghosts"))
+		// Failed pages are read again
+		assert.Equal(t, 2, srv.Reads("/articles/deleted-story"))
+	})
+
+	t.Run("NumItems", func(t *testing.T) {
+		config := DefaultConfig()
+		config.NumItems = 1
+		items, _, err := newSiteReader(srv.Site, config).readItems(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, items, 2)
+	})
+
+	t.Run("MissingListing", func(t *testing.T) {
+		site := Site{Listings: []string{srv.URL + "/articles", srv.URL + "/missing"}}
+		items, failures, err := newSiteReader(site, DefaultConfig()).readItems(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, items, 2)
+		assert.Len(t, failures, 2)
+
+		site = Site{Listings: []string{srv.URL + "/missing"}}
+		_, _, err = newSiteReader(site, DefaultConfig()).readItems(ctx)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSiteReaderCancelled(t *testing.T) {
+	// Mock site whose /articles listing loads at once, while its other
+	// pages only load once the test is over
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/articles" {
+				for i := 0; i < 2*numPageReads; i++ {
+					fmt.Fprintf(w, `<a href="/articles/story-%d">Story</a>`, i)
+				}
+				return
+			}
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+		}))
+	defer srv.Close()
+	defer close(done)
+
+	for _, test := range []struct {
+		Name    string
+		Listing string
+	}{
+		{"Listing", "/places"},
+		{"Pages", "/articles"},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			reader := newSiteReader(Site{Listings: []string{srv.URL + test.Listing}}, DefaultConfig())
+			reader.Timeout = time.Minute
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			start := time.Now()
+			items, failures, err := reader.readItems(ctx)
+			assert.NotNil(t, err)
+			assert.Nil(t, items)
+			assert.Empty(t, reader.pages)
+			if test.Listing == "/articles" {
+				assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+			} else if assert.Len(t, failures, 1) {
+				assert.Contains(t, failures[0].Error, context.Canceled.Error())
+			}
+			assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+		})
+	}
+}
+
+func TestSiteFeed(t *testing.T) {
+	srv := newSiteServer(t)
+	defer srv.Close()
+	source, err := NewSource(srv.Site, DefaultConfig(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := feedkit.NewFeed("/", source, feedkit.NewFeedConfig()).Snapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	atom := string(snapshot.Bodies[feedkit.FormatAtom].Data)
+	assert.Contains(t, atom, "<subtitle>"+SiteDescription+"</subtitle>")
+	assert.Contains(t, atom, `href="https://www.atlasobscura.com/articles/japans-bathroom-ghosts"`)
+	assert.Contains(t, atom, "&lt;img src=&#34;https://img.atlasobscura.com/ghosts-hero.jpg&#34;")
+	assert.Contains(t, atom, "<summary type=\"html\">There are several to keep track of, some scarier than others &amp;amp; some helpful.</summary>")
+}
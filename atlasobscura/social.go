@@ -0,0 +1,219 @@
+package atlasobscura
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+)
+
+var screenNameRE = regexp.MustCompile(`^@?(\w{1,15})$`)
+
+// Names of the platforms of social accounts, by reader
+var platformNames = map[string]string{
+	ReaderTwitter:  "Twitter",
+	ReaderMastodon: "Mastodon",
+	ReaderBluesky:  "Bluesky",
+}
+
+// Account is a social account whose posts are served as a feed of their
+// own, on /social/{platform}/{handle}
+type Account struct {
+	Platform        string        `yaml:"platform"` // twitter, mastodon or bluesky
+	Handle          string        `yaml:"handle"`
+	RefreshInterval time.Duration `yaml:"refresh_interval,omitempty"` // 0 for the feed's
+}
+
+// canonical returns the account with its handle in the form used in feed
+// paths, e.g. "atlasobscura@mastodon.social" for "@AtlasObscura@mastodon.social"
+func (a Account) canonical() (Account, error) {
+	switch a.Platform {
+	case ReaderTwitter:
+		m := screenNameRE.FindStringSubmatch(a.Handle)
+		if m == nil {
+			return a, fmt.Errorf("invalid Twitter screen name %q", a.Handle)
+		}
+		a.Handle = m[1]
+	case ReaderMastodon:
+		username, instance, err := parseAccount(a.Handle)
+		if err != nil {
+			return a, err
+		}
+		a.Handle = username + "@" + strings.TrimPrefix(instance, "https://")
+	case ReaderBluesky:
+		handle, err := parseHandle(a.Handle)
+		if err != nil {
+			return a, err
+		}
+		a.Handle = handle
+	default:
+		return a, fmt.Errorf("unknown platform %q, want %s, %s or %s",
+			a.Platform, ReaderTwitter, ReaderMastodon, ReaderBluesky)
+	}
+	// Handles are case insensitive on all platforms
+	a.Handle = strings.ToLower(a.Handle)
+	return a, nil
+}
+
+// Path returns the path of the feed of the account below /social/
+func (a Account) Path() string {
+	return a.Platform + "/" + a.Handle
+}
+
+// Metadata describes the feed of a canonical account
+func (a Account) Metadata() feedkit.Metadata {
+	link := ""
+	switch a.Platform {
+	case ReaderTwitter:
+		link = "https://twitter.com/" + a.Handle
+	case ReaderMastodon:
+		if username, instance, err := parseAccount(a.Handle); err == nil {
+			link = instance + "/@" + username
+		}
+	case ReaderBluesky:
+		link = "https://bsky.app/profile/" + a.Handle
+	}
+	title := fmt.Sprintf("@%s on %s", a.Handle, platformNames[a.Platform])
+	return feedkit.Metadata{
+		Title:       title,
+		Link:        link,
+		Description: "Links posted by " + title,
+	}
+}
+
+// config returns the config of the reader of the posts of a canonical
+// account
+func (a Account) config(config Config) Config {
+	config.Reader = a.Platform
+	switch a.Platform {
+	case ReaderTwitter:
+		config.ScreenName = a.Handle
+	case ReaderMastodon:
+		config.MastodonAccount = a.Handle
+	case ReaderBluesky:
+		config.BlueskyHandle = a.Handle
+	}
+	if a.RefreshInterval > 0 {
+		config.RefreshInterval = a.RefreshInterval
+	}
+	return config
+}
+
+// NewAccountSource returns a source reading the posts of a social account,
+// with the settings of config other than the reader. The source keeps its
+// history in a store bucket of its own.
+func NewAccountSource(account Account, config Config, store feedkit.Store) (*Source, error) {
+	account, err := account.canonical()
+	if err != nil {
+		return nil, err
+	}
+	source, err := NewSource(Site{}, account.config(config), store)
+	if err != nil {
+		return nil, err
+	}
+	source.account = &account
+	return source, nil
+}
+
+// newSocialSource builds the source of a social account path, e.g.
+// "bluesky/atlasobscura.com". These feeds are built on request, so they
+// keep no history. Mastodon accounts are only served from the instances
+// of config.MastodonInstances, which may only be on public addresses.
+// Tweets are read with the bearer token of the server, so only the screen
+// names of config.TwitterScreenNames are served, and only with a token.
+func newSocialSource(config Config) feedkit.NewSourceFunc {
+	return func(key string) (feedkit.Source, error) {
+		parts := strings.Split(key, "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("no feed at %q: %w", key, feedkit.ErrNotFound)
+		}
+		account, err := Account{Platform: parts[0], Handle: parts[1]}.canonical()
+		if err != nil {
+			return nil, fmt.Errorf("no feed at %q: %v: %w", key, err, feedkit.ErrNotFound)
+		}
+		switch account.Platform {
+		case ReaderTwitter:
+			if !config.TwitterScreenNames.contains(account.Handle) {
+				return nil, fmt.Errorf("no feed at %q: screen name %s is not served: %w",
+					key, account.Handle, feedkit.ErrNotFound)
+			}
+			if _, ok := os.LookupEnv(BearerTokenEnv); !ok {
+				return nil, fmt.Errorf("no feed at %q: %s not set: %w",
+					key, BearerTokenEnv, feedkit.ErrNotFound)
+			}
+		case ReaderMastodon:
+			_, instance, _ := parseAccount(account.Handle)
+			if !config.MastodonInstances.contains(strings.TrimPrefix(instance, "https://")) {
+				return nil, fmt.Errorf("no feed at %q: instance %s is not served: %w",
+					key, instance, feedkit.ErrNotFound)
+			}
+		}
+		source, err := NewAccountSource(account, config, nil)
+		if err != nil {
+			return nil, err
+		}
+		if posts, ok := source.reader.(*mastodonReader); ok {
+			posts.Transport = publicTransport
+		}
+		return source, nil
+	}
+}
+
+// accountList is a comma-separated list of accounts of the form
+// platform/handle, e.g. "bluesky/atlasobscura.com"
+type accountList []Account
+
+func (l *accountList) String() string {
+	paths := make([]string, 0, len(*l))
+	for _, account := range *l {
+		paths = append(paths, account.Path())
+	}
+	return strings.Join(paths, ",")
+}
+
+func (l *accountList) Set(value string) error {
+	*l = nil
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		parts := strings.SplitN(path, "/", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid account %q, want platform/handle", path)
+		}
+		*l = append(*l, Account{Platform: parts[0], Handle: parts[1]})
+	}
+	return nil
+}
+
+// nameList is a comma-separated list of Mastodon instances, e.g.
+// "mastodon.social,hachyderm.io", or of Twitter screen names
+type nameList []string
+
+func (l *nameList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *nameList) Set(value string) error {
+	*l = nil
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*l = append(*l, strings.ToLower(name))
+		}
+	}
+	return nil
+}
+
+// contains reports whether the list has the name, ignoring case and the
+// "@" of screen names
+func (l nameList) contains(name string) bool {
+	for _, n := range l {
+		if strings.EqualFold(strings.TrimPrefix(n, "@"), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package atlasobscura
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountCanonical(t *testing.T) {
+	tests := []struct {
+		Account Account
+		Path    string
+		Link    string
+	}{
+		{Account{Platform: ReaderTwitter, Handle: "@AtlasObscura"},
+			"twitter/atlasobscura", "https://twitter.com/atlasobscura"},
+		{Account{Platform: ReaderMastodon, Handle: "@AtlasObscura@Mastodon.social"},
+			"mastodon/atlasobscura@mastodon.social", "https://mastodon.social/@atlasobscura"},
+		{Account{Platform: ReaderBluesky, Handle: "atlasobscura.com"},
+			"bluesky/atlasobscura.com", "https://bsky.app/profile/atlasobscura.com"},
+	}
+	for _, test := range tests {
+		t.Run(test.Account.Platform, func(t *testing.T) {
+			account, err := test.Account.canonical()
+			assert.Nil(t, err)
+			assert.Equal(t, test.Path, account.Path())
+			assert.Equal(t, test.Link, account.Metadata().Link)
+		})
+	}
+
+	account, _ := Account{Platform: ReaderBluesky, Handle: "atlasobscura.com"}.canonical()
+	assert.Equal(t, feedkit.Metadata{
+		Title:       "@atlasobscura.com on Bluesky",
+		Link:        "https://bsky.app/profile/atlasobscura.com",
+		Description: "Links posted by @atlasobscura.com on Bluesky",
+	}, account.Metadata())
+
+	for _, account := range []Account{
+		{Platform: ReaderTwitter, Handle: "much_too_long_a_name"},
+		{Platform: ReaderMastodon, Handle: "atlasobscura"},
+		{Platform: ReaderSite, Handle: "atlasobscura"},
+	} {
+		_, err := account.canonical()
+		assert.NotNil(t, err, account)
+	}
+}
+
+func TestAccountList(t *testing.T) {
+	var accounts accountList
+	assert.Nil(t, accounts.Set("twitter/atlasobscura, bluesky/atlasobscura.com"))
+	assert.Equal(t, accountList{
+		{Platform: ReaderTwitter, Handle: "atlasobscura"},
+		{Platform: ReaderBluesky, Handle: "atlasobscura.com"},
+	}, accounts)
+	assert.Equal(t, "twitter/atlasobscura,bluesky/atlasobscura.com", accounts.String())
+
+	// Flags are parsed more than once
+	assert.Nil(t, accounts.Set("mastodon/atlasobscura@mastodon.social"))
+	assert.Len(t, accounts, 1)
+	assert.NotNil(t, accounts.Set("atlasobscura"))
+}
+
+func TestNameList(t *testing.T) {
+	var instances nameList
+	assert.Nil(t, instances.Set("Mastodon.social, hachyderm.io,"))
+	assert.Equal(t, nameList{"mastodon.social", "hachyderm.io"}, instances)
+	assert.Equal(t, "mastodon.social,hachyderm.io", instances.String())
+	assert.True(t, instances.contains("HACHYDERM.io"))
+	assert.False(t, instances.contains("example.com"))
+
+	screenNames := nameList{"@AtlasObscura"}
+	assert.True(t, screenNames.contains("atlasobscura"))
+}
+
+func TestSocialSource(t *testing.T) {
+	srv := newBlueskyServer(t)
+	defer srv.Close()
+	config := DefaultConfig()
+	newSource := newSocialSource(config)
+
+	for _, key := range []string{"bluesky", "bluesky/atlasobscura", "site/atlasobscura", "bluesky/atlasobscura.com/posts",
+		"mastodon/atlasobscura@internal.example.com"} {
+		_, err := newSource(key)
+		assert.True(t, errors.Is(err, feedkit.ErrNotFound), key)
+	}
+
+	// Mastodon accounts are only served from the configured instances,
+	// through the transport which only connects to public addresses
+	source, err := newSource("mastodon/atlasobscura@Mastodon.social")
+	if assert.Nil(t, err) {
+		assert.Equal(t, publicTransport, source.(*Source).reader.(*mastodonReader).Transport)
+	}
+	config.MastodonInstances = nameList{"hachyderm.io"}
+	_, err = newSocialSource(config)("mastodon/atlasobscura@mastodon.social")
+	assert.True(t, errors.Is(err, feedkit.ErrNotFound))
+
+	// Twitter accounts are only served from the configured screen names,
+	// with a bearer token
+	_, err = newSource("twitter/atlasobscura")
+	assert.True(t, errors.Is(err, feedkit.ErrNotFound))
+	config.TwitterScreenNames = nameList{"@AtlasObscura"}
+	os.Unsetenv(BearerTokenEnv)
+	_, err = newSocialSource(config)("twitter/atlasobscura")
+	assert.True(t, errors.Is(err, feedkit.ErrNotFound))
+	os.Setenv(BearerTokenEnv, "token")
+	defer os.Unsetenv(BearerTokenEnv)
+	_, err = newSocialSource(config)("twitter/atlasobscura")
+	assert.Nil(t, err)
+	_, err = newSocialSource(config)("twitter/nasa")
+	assert.True(t, errors.Is(err, feedkit.ErrNotFound))
+
+	// Feeds of the set are keyed by their canonical path
+	set := feedkit.NewFeedSet("/social/", time.Minute, feedkit.NewFeedConfig(),
+		func(key string) (feedkit.Source, error) {
+			source, err := newSource(key)
+			if err != nil {
+				return nil, err
+			}
+			s := source.(*Source)
+			assert.Equal(t, ReaderBluesky, s.Config.Reader)
+			assert.Nil(t, s.Store)
+			s.reader = &blueskyReader{API: srv.URL, Handle: s.Config.BlueskyHandle, NumPosts: 20, Timeout: Timeout}
+			return s, nil
+		})
+	for _, path := range []string{"/bluesky/atlasobscura.com", "/bluesky/@AtlasObscura.com"} {
+		rec := httptest.NewRecorder()
+		set.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "<title>@atlasobscura.com on Bluesky</title>")
+	}
+	assert.Equal(t, 2, set.Len())
+	assert.Len(t, srv.requests, 2)
+}
+
+func TestAccountSource(t *testing.T) {
+	ctx := context.Background()
+	srv := newBlueskyServer(t)
+	defer srv.Close()
+	store := feedkit.NewMemoryStore()
+	config := DefaultConfig()
+	account := Account{Platform: ReaderBluesky, Handle: "@atlasobscura.com", RefreshInterval: time.Hour}
+	source, err := NewAccountSource(account, config, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "atlasobscura.com", source.Config.BlueskyHandle)
+	assert.Equal(t, time.Hour, source.Config.RefreshInterval)
+	assert.Equal(t, "items/bluesky/atlasobscura.com", source.historyBucket())
+
+	source.reader = &blueskyReader{API: srv.URL, Handle: "atlasobscura.com", NumPosts: 20, Timeout: Timeout}
+	if _, _, err := source.Fetch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Accounts keep their history apart from the feed
+	items, err := (&Source{Store: store}).Load(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, items)
+	items, err = source.Load(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, items, 3)
+}
@@ -0,0 +1,54 @@
+package atlasobscura
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// errPrivateAddress is returned for connections refused by the public
+// transport
+var errPrivateAddress = errors.New("not a public address")
+
+// publicTransport makes the requests to hosts picked by others, e.g. the
+// links in posts and the instances of on-demand Mastodon feeds. It refuses
+// to connect to loopback, private and link-local addresses, so that feeds
+// cannot be used to reach the network of the server. Tests replace it to
+// reach their own servers.
+var publicTransport http.RoundTripper = newPublicTransport()
+
+// publicIP reports whether ip is an address of the public internet
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// newPublicTransport returns a transport which only connects to public
+// addresses. Addresses are checked as they are dialled, after the host
+// name was resolved, so that a host cannot resolve to another address
+// once it was checked.
+func newPublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%s: %w", address, errPrivateAddress)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Requests through a proxy would only check the address of the proxy
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
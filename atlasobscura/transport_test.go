@@ -0,0 +1,33 @@
+package atlasobscura
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicIP(t *testing.T) {
+	for _, addr := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		assert.True(t, publicIP(net.ParseIP(addr)), addr)
+	}
+	for _, addr := range []string{
+		"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1",
+		"169.254.169.254", "fe80::1", "fd00::1", "0.0.0.0", "::",
+	} {
+		assert.False(t, publicIP(net.ParseIP(addr)), addr)
+	}
+}
+
+func TestPublicTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := http.Client{Transport: newPublicTransport()}
+	_, err := client.Get(srv.URL)
+	assert.True(t, errors.Is(err, errPrivateAddress), "%v", err)
+}
@@ -0,0 +1,173 @@
+package feedkit
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configurable is implemented by the config of a server and of each of its
+// sources
+type Configurable interface {
+	// Flags registers a command line flag, named prefix followed by the
+	// setting name, for each setting. Flags default to the current values.
+	Flags(fs *flag.FlagSet, prefix string)
+	// Validate returns the problems found in the config
+	Validate(prefix string) []string
+}
+
+// ServerConfig holds the settings shared by all feed servers
+type ServerConfig struct {
+	Addr        string        `yaml:"addr"`
+	Timeout     time.Duration `yaml:"timeout"`
+	StaleAfter  time.Duration `yaml:"stale_after"`
+	StorePath   string        `yaml:"store_path"`
+	Author      string        `yaml:"author"`
+	AuthorEmail string        `yaml:"author_email"`
+	Rewrites    []RewriteRule `yaml:"rewrites"`
+}
+
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Addr:        DefaultAddr,
+		Timeout:     DefaultTimeout,
+		Author:      DefaultAuthor,
+		AuthorEmail: DefaultAuthorEmail,
+		Rewrites:    append([]RewriteRule(nil), DefaultRewriteRules...),
+	}
+}
+
+func (c *ServerConfig) Flags(fs *flag.FlagSet, prefix string) {
+	fs.StringVar(&c.Addr, prefix+"addr", c.Addr, "Address to listen on")
+	fs.DurationVar(&c.Timeout, prefix+"timeout", c.Timeout, "Timeout of feed requests")
+	fs.DurationVar(&c.StaleAfter, prefix+"stale-after", c.StaleAfter,
+		"Age of the last successful refresh after which a feed is not ready, 0 for 3 refresh intervals")
+	fs.StringVar(&c.StorePath, prefix+"store-path", c.StorePath,
+		"Path of the on-disk store, feed history is not persisted if empty")
+	fs.StringVar(&c.Author, prefix+"author", c.Author, "Author of the feeds")
+	fs.StringVar(&c.AuthorEmail, prefix+"author-email", c.AuthorEmail, "Email of the feed author")
+}
+
+func (c *ServerConfig) Validate(prefix string) []string {
+	var problems []string
+	if c.Addr == "" {
+		problems = append(problems, prefix+"addr: must be set")
+	}
+	if c.Timeout <= 0 {
+		problems = append(problems, prefix+"timeout: must be positive")
+	}
+	if c.StaleAfter < 0 {
+		problems = append(problems, prefix+"stale-after: must not be negative")
+	}
+	if c.Author == "" {
+		problems = append(problems, prefix+"author: must be set")
+	}
+	return append(problems, validateRewriteRules(c.Rewrites, prefix)...)
+}
+
+// envPrefix returns the prefix of the environment variables of an app,
+// e.g. "HACKERNEWS_" for "hackernews" or "hackernews rewrite", so that apps
+// sharing an environment do not read each other's settings
+func envPrefix(name string) string {
+	fields := strings.Fields(filepath.Base(name))
+	if len(fields) == 0 {
+		return ""
+	}
+	return envName("", fields[0]) + "_"
+}
+
+// envName returns the environment variable overriding a flag, the flag name
+// upper-cased after the app prefix, e.g. FEEDSERVER_HACKERNEWS_REFRESH_INTERVAL
+// for -hackernews.refresh-interval of the feedserver. The app name is not
+// repeated for flags which start with it, e.g. HACKERNEWS_REFRESH_INTERVAL
+// for the same flag of the hackernews server.
+func envName(prefix string, flagName string) string {
+	name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(flagName))
+	return prefix + strings.TrimPrefix(name, prefix)
+}
+
+// LoadConfig fills config, which holds the defaults, from the YAML file
+// named by the -config flag, environment variables and command line flags,
+// in increasing order of precedence. Environment variables are prefixed with
+// the app name, e.g. HACKERNEWS_CONFIG and HACKERNEWS_ADDR for the
+// "hackernews" app. It reports whether -print-config was given, in which
+// case the caller should print the config and exit.
+func LoadConfig(name string, args []string, config Configurable) (bool, error) {
+	prefix := envPrefix(name)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path of the YAML config file")
+	printConfig := fs.Bool("print-config", false, "Print the config and exit")
+	config.Flags(fs, "")
+
+	// Flags are parsed twice, first to find the config file and then to
+	// override the settings in the file and the environment
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+	if *configPath == "" {
+		*configPath = os.Getenv(envName(prefix, "config"))
+	}
+	if *configPath != "" {
+		if err := loadConfigFile(*configPath, config); err != nil {
+			return false, err
+		}
+	}
+
+	var problems []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		env := envName(prefix, f.Name)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			problems = append(problems,
+				fmt.Sprintf("%s: invalid value %q: %v", env, value, err))
+		}
+	})
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+	if fs.NArg() > 0 {
+		problems = append(problems, fmt.Sprintf("unexpected arguments: %v", fs.Args()))
+	}
+
+	problems = append(problems, config.Validate("")...)
+	if len(problems) > 0 {
+		return false, errors.New("invalid config:\n  " + strings.Join(problems, "\n  "))
+	}
+	return *printConfig, nil
+}
+
+func loadConfigFile(path string, config Configurable) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(config); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to load config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// PrintConfig writes config as YAML
+func PrintConfig(w io.Writer, config Configurable) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(config)
+}
@@ -0,0 +1,179 @@
+package feedkit
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSourceConfig struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+func (c *mockSourceConfig) Flags(fs *flag.FlagSet, prefix string) {
+	fs.DurationVar(&c.Interval, prefix+"interval", c.Interval, "Refresh interval")
+}
+
+func (c *mockSourceConfig) Validate(prefix string) []string {
+	if c.Interval <= 0 {
+		return []string{prefix + "interval: must be positive"}
+	}
+	return nil
+}
+
+type mockConfig struct {
+	Server ServerConfig     `yaml:"server"`
+	Mock   mockSourceConfig `yaml:"mock"`
+}
+
+func (c *mockConfig) Flags(fs *flag.FlagSet, prefix string) {
+	c.Server.Flags(fs, prefix)
+	c.Mock.Flags(fs, prefix+"mock.")
+}
+
+func (c *mockConfig) Validate(prefix string) []string {
+	return append(c.Server.Validate(prefix), c.Mock.Validate(prefix+"mock.")...)
+}
+
+func newMockConfig() *mockConfig {
+	return &mockConfig{
+		Server: DefaultServerConfig(),
+		Mock:   mockSourceConfig{Interval: time.Minute},
+	}
+}
+
+func writeConfigFile(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func setenv(t *testing.T, key string, value string) {
+	os.Setenv(key, value)
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		config := newMockConfig()
+		printConfig, err := LoadConfig("test", nil, config)
+		assert.Nil(t, err)
+		assert.False(t, printConfig)
+		assert.Equal(t, newMockConfig(), config)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		path := writeConfigFile(t, `
+server:
+  addr: ":9090"
+  author: Someone
+mock:
+  interval: 5m
+`)
+		config := newMockConfig()
+		_, err := LoadConfig("test", []string{"-config", path}, config)
+		assert.Nil(t, err)
+		assert.Equal(t, ":9090", config.Server.Addr)
+		assert.Equal(t, "Someone", config.Server.Author)
+		assert.Equal(t, DefaultTimeout, config.Server.Timeout)
+		assert.Equal(t, 5*time.Minute, config.Mock.Interval)
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		path := writeConfigFile(t, `
+server:
+  addr: ":9090"
+  timeout: 20s
+mock:
+  interval: 5m
+`)
+		setenv(t, "TEST_TIMEOUT", "30s")
+		setenv(t, "TEST_MOCK_INTERVAL", "10m")
+		config := newMockConfig()
+		_, err := LoadConfig("test", []string{"-config", path, "-mock.interval", "15m"}, config)
+		assert.Nil(t, err)
+		assert.Equal(t, ":9090", config.Server.Addr)
+		assert.Equal(t, 30*time.Second, config.Server.Timeout)
+		assert.Equal(t, 15*time.Minute, config.Mock.Interval)
+	})
+
+	t.Run("ConfigEnv", func(t *testing.T) {
+		setenv(t, "TEST_CONFIG", writeConfigFile(t, "server:\n  addr: \":9191\"\n"))
+		config := newMockConfig()
+		_, err := LoadConfig("test", nil, config)
+		assert.Nil(t, err)
+		assert.Equal(t, ":9191", config.Server.Addr)
+	})
+
+	t.Run("OtherAppEnv", func(t *testing.T) {
+		setenv(t, "ADDR", ":9292")
+		setenv(t, "OTHER_ADDR", ":9393")
+		config := newMockConfig()
+		_, err := LoadConfig("test", nil, config)
+		assert.Nil(t, err)
+		assert.Equal(t, DefaultAddr, config.Server.Addr)
+	})
+
+	t.Run("PrintConfig", func(t *testing.T) {
+		config := newMockConfig()
+		printConfig, err := LoadConfig("test", []string{"-print-config"}, config)
+		assert.Nil(t, err)
+		assert.True(t, printConfig)
+
+		var buf bytes.Buffer
+		assert.Nil(t, PrintConfig(&buf, config))
+		assert.Contains(t, buf.String(), "timeout: 10s")
+		assert.Contains(t, buf.String(), "interval: 1m0s")
+
+		// Printed configs can be loaded back
+		loaded := &mockConfig{}
+		_, err = LoadConfig("test", []string{"-config", writeConfigFile(t, buf.String())}, loaded)
+		assert.Nil(t, err)
+		assert.Equal(t, config, loaded)
+	})
+
+	t.Run("UnknownSetting", func(t *testing.T) {
+		path := writeConfigFile(t, "server:\n  adr: \":9090\"\n")
+		_, err := LoadConfig("test", []string{"-config", path}, newMockConfig())
+		assert.NotNil(t, err)
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := LoadConfig("test", []string{"-config", "/nonexistent.yaml"}, newMockConfig())
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		setenv(t, "TEST_ADDR", "")
+		setenv(t, "TEST_TIMEOUT", "soon")
+		_, err := LoadConfig("test", []string{"-mock.interval", "-1m"}, newMockConfig())
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "addr: must be set")
+			assert.Contains(t, err.Error(), `TEST_TIMEOUT: invalid value "soon"`)
+			assert.Contains(t, err.Error(), "mock.interval: must be positive")
+		}
+	})
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		app, flag, want string
+	}{
+		{"hackernews", "addr", "HACKERNEWS_ADDR"},
+		{"/go/hackernews", "store-path", "HACKERNEWS_STORE_PATH"},
+		{"hackernews rewrite", "config", "HACKERNEWS_CONFIG"},
+		{"hackernews", "hackernews.refresh-interval", "HACKERNEWS_REFRESH_INTERVAL"},
+		{"feedserver", "hackernews.refresh-interval", "FEEDSERVER_HACKERNEWS_REFRESH_INTERVAL"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, envName(envPrefix(tt.app), tt.flag), "%s -%s", tt.app, tt.flag)
+	}
+}
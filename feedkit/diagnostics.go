@@ -0,0 +1,86 @@
+package feedkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ItemError records the failure to fetch a single feed item
+type ItemError struct {
+	Item  string `json:"item"`
+	Error string `json:"error"`
+}
+
+// RefreshStatus describes the outcome of the last refresh of a feed
+type RefreshStatus struct {
+	Time        time.Time   `json:"time"`
+	LastSuccess time.Time   `json:"last_success"`
+	Items       int         `json:"items"`
+	Failures    []ItemError `json:"failures"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// Diagnostics keeps track of the last refresh of every feed
+type Diagnostics struct {
+	mu     sync.Mutex
+	status map[string]RefreshStatus
+}
+
+func NewDiagnostics() *Diagnostics {
+	return &Diagnostics{
+		status: make(map[string]RefreshStatus),
+	}
+}
+
+// TooManyFailures reports whether the share of failed item lookups is
+// above maxRatio
+func TooManyFailures(failed int, total int, maxRatio float64) bool {
+	return total > 0 && float64(failed)/float64(total) > maxRatio
+}
+
+func (d *Diagnostics) Record(feed string, items int, failures []ItemError, err error) {
+	if d == nil {
+		return
+	}
+
+	status := RefreshStatus{
+		Time:     time.Now(),
+		Items:    items,
+		Failures: failures,
+	}
+	if status.Failures == nil {
+		status.Failures = []ItemError{}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		status.Error = err.Error()
+		status.LastSuccess = d.status[feed].LastSuccess
+	} else {
+		status.LastSuccess = status.Time
+	}
+	d.status[feed] = status
+}
+
+func (d *Diagnostics) Status() map[string]RefreshStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := make(map[string]RefreshStatus, len(d.status))
+	for feed, s := range d.status {
+		status[feed] = s
+	}
+	return status
+}
+
+func (d *Diagnostics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(d.Status())
+	})
+}
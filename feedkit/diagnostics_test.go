@@ -0,0 +1,53 @@
+package feedkit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostics(t *testing.T) {
+	diagnostics := NewDiagnostics()
+	diagnostics.Record("beststories", 4, []ItemError{
+		{Item: "27262193", Error: "404 Not Found"},
+	}, nil)
+	diagnostics.Record("newstories", 0, nil, errors.New("3 of 5 story lookups failed"))
+
+	req := httptest.NewRequest("GET", "/diagnostics", nil)
+	rr := httptest.NewRecorder()
+	diagnostics.Handler().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var status map[string]RefreshStatus
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, 4, status["beststories"].Items)
+	assert.Equal(t, []ItemError{{Item: "27262193", Error: "404 Not Found"}},
+		status["beststories"].Failures)
+	assert.Empty(t, status["beststories"].Error)
+	assert.Equal(t, "3 of 5 story lookups failed", status["newstories"].Error)
+	assert.True(t, status["newstories"].LastSuccess.IsZero())
+	assert.False(t, status["beststories"].LastSuccess.IsZero())
+}
+
+func TestDiagnosticsLastSuccess(t *testing.T) {
+	diagnostics := NewDiagnostics()
+	diagnostics.Record("beststories", 4, nil, nil)
+	success := diagnostics.Status()["beststories"].Time
+
+	diagnostics.Record("beststories", 0, nil, errors.New("upstream down"))
+	status := diagnostics.Status()["beststories"]
+	assert.Equal(t, success, status.LastSuccess)
+	assert.True(t, status.Time.After(success) || status.Time.Equal(success))
+}
+
+func TestTooManyFailures(t *testing.T) {
+	assert.False(t, TooManyFailures(0, 0, 0.2))
+	assert.False(t, TooManyFailures(1, 5, 0.2))
+	assert.True(t, TooManyFailures(2, 5, 0.2))
+	assert.False(t, TooManyFailures(2, 5, 0.5))
+	assert.True(t, TooManyFailures(1, 5, 0))
+}
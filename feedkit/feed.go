@@ -0,0 +1,228 @@
+package feedkit
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	DefaultAuthor      = "Venky"
+	DefaultAuthorEmail = "venkytv@gmail.com"
+)
+
+type FeedConfig struct {
+	Cache             *cache.Cache // Feed snapshots
+	Diagnostics       *Diagnostics
+	Author            string
+	AuthorEmail       string
+	Rewriter          *Rewriter // Rewrites the links of items, if set
+	CacheTimeOverride time.Time // Override for testing
+}
+
+func NewFeedConfig() FeedConfig {
+	return FeedConfig{
+		Cache:       cache.New(0, 0), // Cache feeds indefinitely
+		Diagnostics: NewDiagnostics(),
+		Author:      DefaultAuthor,
+		AuthorEmail: DefaultAuthorEmail,
+	}
+}
+
+func (f FeedConfig) CacheTime() time.Time {
+	feedTime := f.CacheTimeOverride
+	if feedTime.IsZero() {
+		feedTime = time.Now()
+	}
+	return feedTime
+}
+
+// Feed serves the pre-rendered snapshots of a source
+type Feed struct {
+	Name   string
+	Source Source
+	Config FeedConfig
+}
+
+func NewFeed(name string, source Source, config FeedConfig) *Feed {
+	return &Feed{
+		Name:   name,
+		Source: source,
+		Config: config,
+	}
+}
+
+func (f *Feed) snapshotKey() string {
+	return "feed:" + f.Name
+}
+
+func (f *Feed) build(items []Item, createTime time.Time) *document {
+	meta := f.Source.Metadata()
+	feed := &feeds.Feed{
+		Title:       meta.Title,
+		Link:        &feeds.Link{Href: meta.Link},
+		Description: meta.Description,
+		Author:      &feeds.Author{Name: f.Config.Author, Email: f.Config.AuthorEmail},
+		Created:     createTime,
+	}
+	doc := &document{Feed: feed}
+	for _, item := range items {
+		feedItem := &feeds.Item{
+			Id:          item.ID,
+			Title:       item.Title,
+			Link:        &feeds.Link{Href: item.Link},
+			Description: item.Description,
+			Content:     item.Content,
+			Created:     item.Created,
+		}
+		if item.Source != "" {
+			feedItem.Source = &feeds.Link{Href: item.Source}
+		}
+		if item.Author != "" {
+			feedItem.Author = &feeds.Author{Name: item.Author}
+		}
+		if e := item.Enclosure; e != nil {
+			feedItem.Enclosure = &feeds.Enclosure{
+				Url:    e.URL,
+				Type:   e.Type,
+				Length: strconv.FormatInt(e.Length, 10),
+			}
+		}
+		feed.Add(feedItem)
+		doc.Categories = append(doc.Categories, item.Categories)
+	}
+	return doc
+}
+
+// update rebuilds the feed snapshot from items. The previous snapshot, and
+// with it the validators sent to clients, is kept if the items have not
+// changed.
+func (f *Feed) update(items []Item) (*FeedSnapshot, error) {
+	items = f.Config.Rewriter.Apply(f.Name, items)
+	sum := checksum(items)
+	version := 1
+	if previous := f.cached(); previous != nil {
+		if previous.Checksum == sum {
+			return previous, nil
+		}
+		version = previous.Version + 1
+	}
+
+	snapshot, err := newFeedSnapshot(f.build(items, f.Config.CacheTime()), sum, version)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Items = items
+
+	f.Config.Cache.Set(f.snapshotKey(), snapshot, cache.NoExpiration)
+	log.Printf("Updated %s feed to version %d", f.Name, version)
+	return snapshot, nil
+}
+
+func (f *Feed) cached() *FeedSnapshot {
+	if cached, found := f.Config.Cache.Get(f.snapshotKey()); found {
+		return cached.(*FeedSnapshot)
+	}
+	return nil
+}
+
+// Refresh fetches the feed items from the source and updates the snapshot
+func (f *Feed) Refresh(ctx context.Context) (*FeedSnapshot, error) {
+	items, failures, err := f.Source.Fetch(ctx)
+	f.Config.Diagnostics.Record(f.Name, len(items), failures, err)
+	if err != nil {
+		log.Printf("Failed to refresh %s feed: %v", f.Name, err)
+		return nil, err
+	}
+	refreshTimes.Set(f.Name, time.Now())
+	return f.update(items)
+}
+
+// Load builds the snapshot from the items persisted by the source, if it
+// is a Loader and the feed has not been fetched yet
+func (f *Feed) Load(ctx context.Context) error {
+	loader, ok := f.Source.(Loader)
+	if !ok || f.cached() != nil {
+		return nil
+	}
+
+	items, err := loader.Load(ctx)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	log.Printf("Loaded %d items of %s feed", len(items), f.Name)
+	_, err = f.update(items)
+	return err
+}
+
+// Snapshot returns the current snapshot, fetching the feed if there is none
+func (f *Feed) Snapshot(ctx context.Context) (*FeedSnapshot, error) {
+	if snapshot := f.cached(); snapshot != nil {
+		return snapshot, nil
+	}
+	log.Print("Feed snapshot not found: ", f.Name)
+	return f.Refresh(ctx)
+}
+
+func (f *Feed) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	format := f.serve(rec, req)
+	if format == "" {
+		format = "unknown"
+	}
+	observeFeedRequest(f.Name, string(format), rec.status)
+}
+
+// serve responds to a feed request, returning the format requested
+func (f *Feed) serve(w http.ResponseWriter, req *http.Request) FeedFormat {
+	format, err := negotiateFormat(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return ""
+	}
+
+	var filter ItemFilter
+	if filterer, ok := f.Source.(Filterer); ok {
+		filter, err = filterer.Filter(req.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return format
+		}
+	}
+
+	snapshot, err := f.Snapshot(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return format
+	}
+
+	if filter == nil {
+		snapshot.Serve(w, req, format)
+		return format
+	}
+
+	// Filtered feeds are rendered from the snapshot's items
+	items := make([]Item, 0, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		if filter(item) {
+			items = append(items, item)
+		}
+	}
+	data, err := renderFeed(f.build(items, snapshot.Modified), format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return format
+	}
+	body, err := newFeedBody([]byte(data))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return format
+	}
+	serveFeedBody(w, req, body, snapshot.Modified, format)
+	return format
+}
@@ -0,0 +1,194 @@
+package feedkit
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSource struct {
+	Items    []Item
+	Failures []ItemError
+	Error    error
+	Fetches  int
+}
+
+func (s *mockSource) Metadata() Metadata {
+	return Metadata{
+		Title:       "Mock",
+		Link:        "https://example.com",
+		Description: "Mock Feed",
+	}
+}
+
+func (s *mockSource) Fetch(context.Context) ([]Item, []ItemError, error) {
+	s.Fetches++
+	return s.Items, s.Failures, s.Error
+}
+
+// Filters items by title with the "q" query parameter
+func (s *mockSource) Filter(params url.Values) (ItemFilter, error) {
+	q := params.Get("q")
+	if q == "" {
+		return nil, nil
+	}
+	if q == "bad" {
+		return nil, errors.New("bad filter")
+	}
+	return func(item Item) bool {
+		return strings.Contains(item.Title, q)
+	}, nil
+}
+
+type mockLoader struct {
+	mockSource
+	Stored []Item
+}
+
+func (s *mockLoader) Load(context.Context) ([]Item, error) {
+	return s.Stored, nil
+}
+
+func newMockFeed(source Source) *Feed {
+	feedConfig := NewFeedConfig()
+	feedConfig.CacheTimeOverride = time.Date(2021, time.May, 23, 20, 51, 39, 0, time.UTC)
+	return NewFeed("/mock", source, feedConfig)
+}
+
+func TestFeed(t *testing.T) {
+	created := time.Date(2021, time.May, 2, 14, 0, 26, 0, time.UTC)
+	source := &mockSource{
+		Items: []Item{
+			{
+				ID:      "1",
+				Title:   "The world's smallest Dala horse",
+				Link:    "https://example.com/horse",
+				Created: created,
+			},
+			{
+				ID:      "2",
+				Title:   "Japan's bathroom ghosts",
+				Link:    "https://example.com/ghosts",
+				Source:  "https://example.com/discussion/2",
+				Created: created.Add(-time.Hour),
+			},
+		},
+	}
+	feed := newMockFeed(source)
+
+	serve := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		feed.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("DefaultFormat", func(t *testing.T) {
+		rr := serve("/", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, FormatAtom.ContentType(), rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), "<title>Mock</title>")
+		assert.Contains(t, rr.Body.String(), "<name>Venky</name>")
+		assert.Equal(t, 2, strings.Count(rr.Body.String(), "<entry>"))
+		assert.Equal(t, 1, source.Fetches)
+	})
+
+	t.Run("AcceptRSS", func(t *testing.T) {
+		rr := serve("/", http.Header{"Accept": {"application/rss+xml"}})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, FormatRSS.ContentType(), rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), "<rss")
+	})
+
+	t.Run("QueryJSON", func(t *testing.T) {
+		rr := serve("/?format=json", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, FormatJSON.ContentType(), rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), JSONFeedVersion)
+	})
+
+	t.Run("BadFormat", func(t *testing.T) {
+		rr := serve("/?format=html", nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("NotModified", func(t *testing.T) {
+		snapshot, err := feed.Snapshot(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := serve("/", http.Header{"If-None-Match": {snapshot.Bodies[FormatAtom].ETag}})
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Equal(t, "Sun, 23 May 2021 20:51:39 GMT", rr.Header().Get("Last-Modified"))
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		rr := serve("/?q=ghosts", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 1, strings.Count(rr.Body.String(), "<entry>"))
+		assert.Contains(t, rr.Body.String(), "https://example.com/ghosts")
+
+		rr = serve("/?q=ghosts&format=rss", nil)
+		assert.Contains(t, rr.Body.String(), "<source>https://example.com/discussion/2</source>")
+
+		rr = serve("/?q=bad", nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("RefreshUnchanged", func(t *testing.T) {
+		before, _ := feed.Snapshot(context.Background())
+		after, err := feed.Refresh(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, before.Version, after.Version)
+	})
+
+	t.Run("RefreshChanged", func(t *testing.T) {
+		source.Items = source.Items[:1]
+		snapshot, err := feed.Refresh(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 2, snapshot.Version)
+	})
+
+	t.Run("RefreshFailed", func(t *testing.T) {
+		source.Error = errors.New("upstream down")
+		_, err := feed.Refresh(context.Background())
+		assert.NotNil(t, err)
+		assert.Equal(t, "upstream down", feed.Config.Diagnostics.Status()["/mock"].Error)
+
+		// The previous snapshot is still served
+		rr := serve("/", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
+
+func TestFeedLoad(t *testing.T) {
+	source := &mockLoader{
+		Stored: []Item{{ID: "1", Title: "Stored", Link: "https://example.com/1"}},
+	}
+	feed := newMockFeed(source)
+
+	assert.Nil(t, feed.Load(context.Background()))
+	snapshot, err := feed.Snapshot(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, source.Stored, snapshot.Items)
+	assert.Equal(t, 0, source.Fetches)
+}
+
+func TestMain(m *testing.M) {
+	// Skip log messages during testing
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
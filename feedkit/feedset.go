@@ -0,0 +1,216 @@
+package feedkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	// How long the feeds of a FeedSet are kept after they were last requested
+	FeedSetExpiry = 24 * time.Hour
+	// Default MaxFeeds of a FeedSet
+	FeedSetMaxFeeds = 1000
+	// Default Retry of a FeedSet
+	FeedSetRetry = time.Minute
+)
+
+var (
+	// ErrNotFound is returned by sources, or wrapped in their errors, when
+	// the feed they were asked for does not exist upstream
+	ErrNotFound = errors.New("not found")
+
+	// ErrBadRequest is wrapped in the errors of sources which cannot be
+	// built from the request, e.g. because of invalid query parameters
+	ErrBadRequest = errors.New("bad request")
+
+	// ErrSetFull is returned for new feeds of a FeedSet which holds
+	// MaxFeeds feeds already
+	ErrSetFull = errors.New("too many feeds")
+)
+
+// NewSourceFunc builds the source of the feed named by key, by default the
+// rest of the request path, e.g. "123/comments" for "/item/123/comments".
+// It returns an error wrapping ErrNotFound if the key names no feed.
+type NewSourceFunc func(key string) (Source, error)
+
+// KeyFunc names the feed of a FeedSet a request is for
+type KeyFunc func(req *http.Request) (string, error)
+
+// PathKey names feeds by the request path below the FeedSet
+func PathKey(req *http.Request) (string, error) {
+	return strings.Trim(req.URL.Path, "/"), nil
+}
+
+// setFeed is a feed of a FeedSet, with the time it was last refreshed and
+// the outcome of the refreshes which failed since
+type setFeed struct {
+	mu        sync.Mutex
+	feed      *Feed
+	refreshed time.Time
+	failures  int       // Failed refreshes since the last successful one
+	retry     time.Time // Time before which a failed feed is not refreshed
+	err       error     // Error of the last failed refresh
+}
+
+// FeedSet serves feeds built on demand from request paths, e.g. one feed
+// per story or per user. Feeds are fetched when they are first requested,
+// refreshed on request once they are older than MaxAge and dropped once
+// they have not been requested for FeedSetExpiry. A feed which fails to
+// refresh is not refreshed again for Retry, doubled after every further
+// failure up to MaxAge, and feeds which never refreshed are dropped once
+// they may be retried. New feeds are refused once the set holds MaxFeeds.
+type FeedSet struct {
+	Name      string
+	MaxAge    time.Duration
+	MaxFeeds  int           // 0 for no limit
+	Retry     time.Duration // Wait after a failed refresh
+	Config    FeedConfig
+	Key       KeyFunc
+	NewSource NewSourceFunc
+
+	mu    sync.Mutex
+	feeds *cache.Cache
+}
+
+func NewFeedSet(name string, maxAge time.Duration, config FeedConfig, newSource NewSourceFunc) *FeedSet {
+	return &FeedSet{
+		Name:      name,
+		MaxAge:    maxAge,
+		MaxFeeds:  FeedSetMaxFeeds,
+		Retry:     FeedSetRetry,
+		Config:    config,
+		Key:       PathKey,
+		NewSource: newSource,
+		feeds:     cache.New(FeedSetExpiry, time.Minute),
+	}
+}
+
+// feed returns the feed for key, creating it if it is not in the set
+func (s *FeedSet) feed(key string) (*setFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached, found := s.feeds.Get(key); found {
+		return cached.(*setFeed), nil
+	}
+	if s.MaxFeeds > 0 && s.feeds.ItemCount() >= s.MaxFeeds {
+		s.feeds.DeleteExpired()
+		if s.feeds.ItemCount() >= s.MaxFeeds {
+			return nil, fmt.Errorf("%s holds %d feeds: %w", s.Name, s.MaxFeeds, ErrSetFull)
+		}
+	}
+
+	source, err := s.NewSource(key)
+	if err != nil {
+		return nil, err
+	}
+	// Feeds of a set share the name of the set in diagnostics and metrics,
+	// so they keep their snapshots in caches of their own
+	config := s.Config
+	config.Cache = cache.New(0, 0)
+	f := &setFeed{feed: NewFeed(s.Name, source, config)}
+	s.feeds.SetDefault(key, f)
+	return f, nil
+}
+
+// backoff returns how long a feed is not refreshed after its last failed
+// refresh
+func (s *FeedSet) backoff(failures int) time.Duration {
+	backoff := s.Retry
+	for i := 1; i < failures && backoff < s.MaxAge; i++ {
+		backoff *= 2
+	}
+	if backoff > s.MaxAge && s.MaxAge > s.Retry {
+		backoff = s.MaxAge
+	}
+	return backoff
+}
+
+// refresh refreshes a feed of the set if it is older than MaxAge, and it
+// is not backing off from a failed refresh. A feed which fails to refresh
+// keeps serving its previous snapshot, if any, while it backs off. It
+// returns how long the feed is kept in the set.
+func (s *FeedSet) refresh(ctx context.Context, f *setFeed) (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.refreshed) < s.MaxAge {
+		return FeedSetExpiry, nil
+	}
+	if time.Now().Before(f.retry) {
+		return s.failed(f, f.err)
+	}
+	if _, err := f.feed.Refresh(ctx); err != nil {
+		f.failures++
+		f.err = err
+		f.retry = time.Now().Add(s.backoff(f.failures))
+		return s.failed(f, err)
+	}
+	f.refreshed = time.Now()
+	f.failures = 0
+	f.err = nil
+	f.retry = time.Time{}
+	return FeedSetExpiry, nil
+}
+
+// failed returns the error served for a feed backing off from a failed
+// refresh, if it has no snapshot to serve, and how long it is kept
+func (s *FeedSet) failed(f *setFeed, err error) (time.Duration, error) {
+	if f.feed.cached() == nil {
+		// Feeds which never refreshed are only kept to back off
+		return time.Until(f.retry), err
+	}
+	if errors.Is(err, ErrNotFound) {
+		return FeedSetExpiry, err
+	}
+	return FeedSetExpiry, nil
+}
+
+// Len returns the number of feeds in the set
+func (s *FeedSet) Len() int {
+	return s.feeds.ItemCount()
+}
+
+func (s *FeedSet) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var f *setFeed
+	key, err := s.Key(req)
+	if err == nil {
+		f, err = s.feed(key)
+	}
+	if err == nil {
+		var keep time.Duration
+		keep, err = s.refresh(req.Context(), f)
+		// Keep requested feeds around for another FeedSetExpiry, or until
+		// a feed which never refreshed may be retried
+		if keep > 0 {
+			s.feeds.Set(key, f, keep)
+		} else {
+			s.feeds.Delete(key)
+		}
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrBadRequest):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrSetFull):
+			status = http.StatusServiceUnavailable
+		default:
+			log.Printf("Failed to serve %s feed %q: %v", s.Name, key, err)
+		}
+		http.Error(w, err.Error(), status)
+		observeFeedRequest(s.Name, "unknown", status)
+		return
+	}
+	f.feed.ServeHTTP(w, req)
+}
@@ -0,0 +1,145 @@
+package feedkit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedSet(t *testing.T) {
+	sources := map[string]*mockSource{
+		"one": {Items: []Item{{ID: "1", Title: "One"}}},
+		"two": {Items: []Item{{ID: "2", Title: "Two"}}},
+	}
+	server := newTestServer()
+	set := server.MountSet("/set/", func(key string) (Source, error) {
+		source, found := sources[key]
+		if !found {
+			return nil, fmt.Errorf("feed %q: %w", key, ErrNotFound)
+		}
+		return source, nil
+	}, time.Hour)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.mux.ServeHTTP(rr, httptest.NewRequest("GET", path+"?format=json", nil))
+		return rr
+	}
+
+	t.Run("Serve", func(t *testing.T) {
+		rr := get("/set/one")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"title": "One"`)
+		rr = get("/set/two/")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"title": "Two"`)
+		assert.Equal(t, 2, set.Len())
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		// Feeds are only refreshed once they are older than MaxAge
+		get("/set/one")
+		assert.Equal(t, 1, sources["one"].Fetches)
+
+		set.MaxAge = 0
+		sources["one"].Items = []Item{{ID: "3", Title: "Three"}}
+		rr := get("/set/one")
+		assert.Equal(t, 2, sources["one"].Fetches)
+		assert.Contains(t, rr.Body.String(), `"title": "Three"`)
+
+		// Failed refreshes keep serving the previous snapshot, and the
+		// feed is not refreshed again until it has backed off
+		sources["one"].Error = errors.New("upstream down")
+		rr = get("/set/one")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"title": "Three"`)
+		rr = get("/set/one")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 3, sources["one"].Fetches)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		rr := get("/set/three")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Contains(t, rr.Body.String(), "not found")
+
+		// Feeds which failed to refresh are kept while they back off, so
+		// that they are not fetched on every request
+		sources["four"] = &mockSource{Error: fmt.Errorf("gone: %w", ErrNotFound)}
+		rr = get("/set/four")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		rr = get("/set/four")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, 1, sources["four"].Fetches)
+		assert.Equal(t, 3, set.Len())
+
+		// They are dropped once they may be retried
+		set.Retry = 0
+		sources["five"] = &mockSource{Error: errors.New("upstream down")}
+		rr = get("/set/five")
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Equal(t, 3, set.Len())
+		get("/set/five")
+		assert.Equal(t, 2, sources["five"].Fetches)
+	})
+
+	t.Run("MaxFeeds", func(t *testing.T) {
+		set.MaxFeeds = 3
+		sources["six"] = &mockSource{Items: []Item{{ID: "6", Title: "Six"}}}
+		rr := get("/set/six")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, 0, sources["six"].Fetches)
+
+		// Feeds already in the set are still served
+		assert.Equal(t, http.StatusOK, get("/set/two").Code)
+	})
+}
+
+func TestFeedSetBackoff(t *testing.T) {
+	set := NewFeedSet("/set/", 30*time.Minute, NewFeedConfig(), nil)
+	assert.Equal(t, time.Minute, set.backoff(1))
+	assert.Equal(t, 2*time.Minute, set.backoff(2))
+	assert.Equal(t, 16*time.Minute, set.backoff(5))
+	assert.Equal(t, 30*time.Minute, set.backoff(6))
+	assert.Equal(t, 30*time.Minute, set.backoff(100))
+
+	// Sets refreshed more often than Retry still back off for Retry
+	set.MaxAge = time.Second
+	assert.Equal(t, time.Minute, set.backoff(3))
+}
+
+func TestFeedSetKey(t *testing.T) {
+	source := &mockSource{Items: []Item{{ID: "1", Title: "One"}}}
+	server := newTestServer()
+	set := server.MountSet("/search", func(key string) (Source, error) {
+		return source, nil
+	}, time.Hour)
+	set.Key = func(req *http.Request) (string, error) {
+		q := req.URL.Query().Get("q")
+		if q == "" {
+			return "", fmt.Errorf("missing query: %w", ErrBadRequest)
+		}
+		return q, nil
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, get("/search?q=go").Code)
+	assert.Equal(t, http.StatusOK, get("/search?q=go&format=json").Code)
+	assert.Equal(t, 1, set.Len())
+	assert.Equal(t, 1, source.Fetches)
+
+	rr := get("/search")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "missing query")
+	assert.Equal(t, 1, set.Len())
+}
@@ -0,0 +1,224 @@
+package feedkit
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/feeds"
+)
+
+type FeedFormat string
+
+const (
+	FormatAtom FeedFormat = "atom"
+	FormatRSS  FeedFormat = "rss"
+	FormatJSON FeedFormat = "json"
+
+	DefaultFormat   = FormatAtom
+	JSONFeedVersion = "https://jsonfeed.org/version/1.1"
+)
+
+// Formats every feed is rendered in
+var FeedFormats = []FeedFormat{FormatAtom, FormatRSS, FormatJSON}
+
+var contentTypes = map[FeedFormat]string{
+	FormatAtom: "application/atom+xml; charset=utf-8",
+	FormatRSS:  "application/rss+xml; charset=utf-8",
+	FormatJSON: "application/feed+json; charset=utf-8",
+}
+
+// Media types accepted in the Accept header for each format
+var mediaTypes = map[string]FeedFormat{
+	"application/atom+xml":  FormatAtom,
+	"application/xml":       FormatAtom,
+	"text/xml":              FormatAtom,
+	"application/rss+xml":   FormatRSS,
+	"application/feed+json": FormatJSON,
+	"application/json":      FormatJSON,
+}
+
+func (f FeedFormat) ContentType() string {
+	return contentTypes[f]
+}
+
+func parseFormat(name string) (FeedFormat, error) {
+	format := FeedFormat(strings.ToLower(name))
+	if _, ok := contentTypes[format]; !ok {
+		return "", fmt.Errorf("unsupported feed format: %q", name)
+	}
+	return format, nil
+}
+
+// negotiateFormat picks the feed format from the "format" query parameter,
+// falling back to the most preferred type in the Accept header
+func negotiateFormat(req *http.Request) (FeedFormat, error) {
+	if name := req.URL.Query().Get("format"); name != "" {
+		return parseFormat(name)
+	}
+
+	type accepted struct {
+		Format FeedFormat
+		Q      float64
+	}
+	formats := make([]accepted, 0)
+	for _, part := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		format, ok := mediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			formats = append(formats, accepted{format, q})
+		}
+	}
+	if len(formats) == 0 {
+		return DefaultFormat, nil
+	}
+
+	sort.SliceStable(formats, func(i, j int) bool {
+		return formats[i].Q > formats[j].Q
+	})
+	return formats[0].Format, nil
+}
+
+// toJSONFeed renders a JSON Feed 1.1 document. gorilla/feeds only speaks
+// version 1, which lists a single author and allows items without content.
+func toJSONFeed(feed *document) (string, error) {
+	jsonFeed := (&feeds.JSON{Feed: feed.Feed}).JSONFeed()
+	jsonFeed.Version = JSONFeedVersion
+
+	doc := struct {
+		*feeds.JSONFeed
+		Authors []*feeds.JSONAuthor `json:"authors,omitempty"`
+	}{JSONFeed: jsonFeed}
+	if jsonFeed.Author != nil {
+		doc.Authors = []*feeds.JSONAuthor{jsonFeed.Author}
+	}
+
+	for idx, item := range jsonFeed.Items {
+		item.Tags = feed.Categories[idx]
+		if item.ContentHTML == "" {
+			item.ContentHTML = item.Summary
+		}
+		if item.ContentHTML == "" {
+			item.ContentText = item.Title
+		}
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// document is a feed ready to be rendered, along with the categories of
+// each of its items, which gorilla/feeds does not model
+type document struct {
+	*feeds.Feed
+	Categories [][]string // By item index
+}
+
+// atomEntry adds categories to an Atom entry. gorilla/feeds renders a
+// single category as text, while Atom keeps it in the term attribute.
+type atomEntry struct {
+	XMLName xml.Name `xml:"entry"`
+	*feeds.AtomEntry
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name `xml:"feed"`
+	*feeds.AtomFeed
+	Entries []*atomEntry `xml:"entry"`
+}
+
+func (f *atomFeed) FeedXml() interface{} {
+	return f
+}
+
+func toAtom(doc *document) (string, error) {
+	atom := (&feeds.Atom{Feed: doc.Feed}).AtomFeed()
+	feed := &atomFeed{AtomFeed: atom}
+	for idx, entry := range atom.Entries {
+		e := &atomEntry{AtomEntry: entry}
+		for _, term := range doc.Categories[idx] {
+			e.Categories = append(e.Categories, atomCategory{term})
+		}
+		feed.Entries = append(feed.Entries, e)
+	}
+	return feeds.ToXML(feed)
+}
+
+// rssItem adds categories to an RSS item, which gorilla/feeds limits to
+// one
+type rssItem struct {
+	XMLName xml.Name `xml:"item"`
+	*feeds.RssItem
+	Categories []string `xml:"category"`
+}
+
+type rssChannel struct {
+	XMLName xml.Name `xml:"channel"`
+	*feeds.RssFeed
+	Items []*rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName          xml.Name `xml:"rss"`
+	Version          string   `xml:"version,attr"`
+	ContentNamespace string   `xml:"xmlns:content,attr"`
+	Channel          *rssChannel
+}
+
+func (f *rssFeed) FeedXml() interface{} {
+	return f
+}
+
+func toRSS(doc *document) (string, error) {
+	rss := (&feeds.Rss{Feed: doc.Feed}).RssFeed()
+	xmlFeed := rss.FeedXml().(*feeds.RssFeedXml)
+	channel := &rssChannel{RssFeed: rss}
+	for idx, item := range rss.Items {
+		channel.Items = append(channel.Items, &rssItem{
+			RssItem:    item,
+			Categories: doc.Categories[idx],
+		})
+	}
+	return feeds.ToXML(&rssFeed{
+		Version:          xmlFeed.Version,
+		ContentNamespace: xmlFeed.ContentNamespace,
+		Channel:          channel,
+	})
+}
+
+// renderFeed renders a feed in the given format
+func renderFeed(doc *document, format FeedFormat) (string, error) {
+	switch format {
+	case FormatRSS:
+		return toRSS(doc)
+	case FormatJSON:
+		return toJSONFeed(doc)
+	default:
+		return toAtom(doc)
+	}
+}
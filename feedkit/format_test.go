@@ -0,0 +1,131 @@
+package feedkit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Query  string
+		Accept string
+		Want   FeedFormat
+	}{
+		{"Default", "", "", FormatAtom},
+		{"AnyType", "", "*/*", FormatAtom},
+		{"QueryRSS", "?format=rss", "", FormatRSS},
+		{"QueryJSON", "?format=JSON", "application/atom+xml", FormatJSON},
+		{"AcceptRSS", "", "application/rss+xml", FormatRSS},
+		{"AcceptJSONFeed", "", "application/feed+json", FormatJSON},
+		{"AcceptQuality", "", "application/atom+xml;q=0.5, application/rss+xml", FormatRSS},
+		{"AcceptUnknown", "", "text/html", FormatAtom},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/"+test.Query, nil)
+			if test.Accept != "" {
+				req.Header.Set("Accept", test.Accept)
+			}
+			format, err := negotiateFormat(req)
+			assert.Nil(t, err)
+			assert.Equal(t, test.Want, format)
+		})
+	}
+
+	t.Run("BadFormat", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/?format=html", nil)
+		_, err := negotiateFormat(req)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestJSONFeed(t *testing.T) {
+	feed := &feeds.Feed{
+		Title:   "Hacker News",
+		Link:    &feeds.Link{Href: "https://news.ycombinator.com/best"},
+		Author:  &feeds.Author{Name: DefaultAuthor},
+		Created: time.Date(2021, time.May, 25, 10, 0, 0, 0, time.UTC),
+	}
+	feed.Add(&feeds.Item{
+		Title:   "Show HN: Something",
+		Link:    &feeds.Link{Href: "https://example.com"},
+		Id:      "https://news.ycombinator.com/item?id=1",
+		Created: time.Date(2021, time.May, 24, 10, 0, 0, 0, time.UTC),
+	})
+
+	body, err := renderFeed(&document{Feed: feed, Categories: [][]string{{"test"}}}, FormatJSON)
+	assert.Nil(t, err)
+
+	var doc struct {
+		Version     string `json:"version"`
+		HomePageURL string `json:"home_page_url"`
+		Authors     []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+		Items []struct {
+			ID          string   `json:"id"`
+			URL         string   `json:"url"`
+			ContentText string   `json:"content_text"`
+			Tags        []string `json:"tags"`
+		} `json:"items"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(body), &doc))
+	assert.Equal(t, JSONFeedVersion, doc.Version)
+	assert.Equal(t, "https://news.ycombinator.com/best", doc.HomePageURL)
+	assert.Len(t, doc.Authors, 1)
+	assert.Len(t, doc.Items, 1)
+	assert.Equal(t, "https://news.ycombinator.com/item?id=1", doc.Items[0].ID)
+	assert.Equal(t, "Show HN: Something", doc.Items[0].ContentText)
+	assert.Equal(t, []string{"test"}, doc.Items[0].Tags)
+}
+
+func TestCategories(t *testing.T) {
+	source := &mockSource{Items: []Item{
+		{ID: "1", Title: "Job", Author: "Someone", Categories: []string{"job", "remote"}},
+		{ID: "2", Title: "Story"},
+	}}
+	feed := NewFeed("/categories", source, NewFeedConfig())
+	snapshot, err := feed.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atom := string(snapshot.Bodies[FormatAtom].Data)
+	assert.Contains(t, atom, `<category term="job"></category>`)
+	assert.Contains(t, atom, `<category term="remote"></category>`)
+	assert.Contains(t, atom, `<name>Someone</name>`)
+	assert.Equal(t, 2, strings.Count(atom, "<category"))
+
+	rss := string(snapshot.Bodies[FormatRSS].Data)
+	assert.Contains(t, rss, `<category>job</category>`)
+	assert.Contains(t, rss, `<category>remote</category>`)
+}
+
+func TestEnclosure(t *testing.T) {
+	source := &mockSource{Items: []Item{{
+		ID:        "1",
+		Title:     "Card",
+		Link:      "https://example.com/post",
+		Enclosure: &Enclosure{URL: "https://example.com/thumb.jpg", Type: "image/jpeg"},
+	}}}
+	snapshot, err := NewFeed("/enclosure", source, NewFeedConfig()).Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, string(snapshot.Bodies[FormatAtom].Data),
+		`<link href="https://example.com/thumb.jpg" rel="enclosure" type="image/jpeg" length="0">`)
+	assert.Contains(t, string(snapshot.Bodies[FormatRSS].Data),
+		`<enclosure url="https://example.com/thumb.jpg" length="0" type="image/jpeg"></enclosure>`)
+	assert.Contains(t, string(snapshot.Bodies[FormatJSON].Data),
+		`"image": "https://example.com/thumb.jpg"`)
+}
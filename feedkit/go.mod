@@ -0,0 +1,15 @@
+module duh-uh.com/app/rss-feeds/feedkit
+
+go 1.15
+
+require (
+	github.com/gorilla/feeds v1.1.1
+	github.com/kr/pretty v0.2.1 // indirect
+	github.com/patrickmn/go-cache v2.1.0+incompatible
+	github.com/prometheus/client_golang v1.12.2
+	github.com/stretchr/testify v1.7.0
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/sys v0.10.0 // indirect
+	google.golang.org/protobuf v1.27.1 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
+)
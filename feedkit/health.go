@@ -0,0 +1,90 @@
+package feedkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Refresh intervals a feed can miss before it is considered stale, unless
+// the server has a StaleAfter threshold
+const StaleRefreshes = 3
+
+// FeedHealth is the readiness of a single feed
+type FeedHealth struct {
+	Ready       bool       `json:"ready"`
+	Reason      string     `json:"reason,omitempty"`
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Health is the readiness of a server, which is ready once all of its
+// feeds are
+type Health struct {
+	Ready bool                  `json:"ready"`
+	Feeds map[string]FeedHealth `json:"feeds"`
+}
+
+// staleAfter returns how long after its last successful refresh a feed
+// is stale
+func (s *Server) staleAfter(m mount) time.Duration {
+	if s.StaleAfter > 0 {
+		return s.StaleAfter
+	}
+	return StaleRefreshes * m.RefreshInterval
+}
+
+func (s *Server) health(now time.Time) Health {
+	status := s.FeedConfig.Diagnostics.Status()
+	health := Health{
+		Ready: true,
+		Feeds: make(map[string]FeedHealth, len(s.mounts)),
+	}
+	for _, m := range s.mounts {
+		var feed FeedHealth
+		refresh, found := status[m.Feed.Name]
+		switch {
+		case !found:
+			feed.Reason = "not refreshed yet"
+		case refresh.LastSuccess.IsZero():
+			feed.Reason = "no successful refresh yet"
+		case now.Sub(refresh.LastSuccess) > s.staleAfter(m):
+			feed.Reason = fmt.Sprintf("last successful refresh older than %v",
+				s.staleAfter(m))
+		default:
+			feed.Ready = true
+		}
+		if found {
+			feed.LastRefresh = &refresh.Time
+			feed.Error = refresh.Error
+			if !refresh.LastSuccess.IsZero() {
+				feed.LastSuccess = &refresh.LastSuccess
+			}
+		}
+
+		health.Feeds[m.Feed.Name] = feed
+		health.Ready = health.Ready && feed.Ready
+	}
+	return health
+}
+
+// healthzHandler reports that the process is live
+func healthzHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
+
+// readyzHandler reports whether every feed has been refreshed recently,
+// failing with 503 Service Unavailable otherwise
+func (s *Server) readyzHandler(w http.ResponseWriter, req *http.Request) {
+	health := s.health(time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	if !health.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(health)
+}
@@ -0,0 +1,73 @@
+package feedkit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	server := newTestServer()
+	rr := httptest.NewRecorder()
+	server.mux.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rr.Body.String())
+}
+
+func TestReadyz(t *testing.T) {
+	server := newTestServer()
+	server.Mount("/one", &mockSource{}, time.Minute)
+	server.Mount("/two", &mockSource{}, 10*time.Minute)
+	diagnostics := server.FeedConfig.Diagnostics
+
+	readyz := func() (int, Health) {
+		rr := httptest.NewRecorder()
+		server.mux.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+		var health Health
+		if err := json.Unmarshal(rr.Body.Bytes(), &health); err != nil {
+			t.Fatal(err)
+		}
+		return rr.Code, health
+	}
+
+	t.Run("NotRefreshed", func(t *testing.T) {
+		diagnostics.Record("/one", 1, nil, nil)
+		diagnostics.Record("/two", 0, nil, errors.New("upstream down"))
+
+		code, health := readyz()
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.False(t, health.Ready)
+		assert.True(t, health.Feeds["/one"].Ready)
+		assert.NotNil(t, health.Feeds["/one"].LastSuccess)
+		assert.False(t, health.Feeds["/two"].Ready)
+		assert.Equal(t, "upstream down", health.Feeds["/two"].Error)
+		assert.Equal(t, "no successful refresh yet", health.Feeds["/two"].Reason)
+		assert.Nil(t, health.Feeds["/two"].LastSuccess)
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		diagnostics.Record("/two", 1, nil, nil)
+		code, health := readyz()
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, health.Ready)
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		// Feeds are stale after missing StaleRefreshes refreshes
+		health := server.health(time.Now().Add(5 * time.Minute))
+		assert.False(t, health.Ready)
+		assert.False(t, health.Feeds["/one"].Ready)
+		assert.True(t, health.Feeds["/two"].Ready)
+
+		server.StaleAfter = time.Hour
+		health = server.health(time.Now().Add(5 * time.Minute))
+		assert.True(t, health.Ready)
+		health = server.health(time.Now().Add(2 * time.Hour))
+		assert.False(t, health.Ready)
+	})
+}
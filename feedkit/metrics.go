@@ -0,0 +1,118 @@
+package feedkit
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feeds_upstream_requests_total",
+		Help: "Upstream API calls made by sources, by outcome.",
+	}, []string{"source", "call", "result"})
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "feeds_upstream_request_duration_seconds",
+		Help:    "Latency of upstream API calls made by sources.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"source", "call"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feeds_cache_lookups_total",
+		Help: "Cache lookups made by sources, by hit or miss.",
+	}, []string{"cache", "result"})
+
+	cacheSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "feeds_cache_items",
+		Help: "Number of items in a source cache.",
+	}, []string{"cache"})
+
+	feedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feeds_requests_total",
+		Help: "Feed requests served, by status code and format.",
+	}, []string{"feed", "format", "code"})
+
+	refreshTimes = &refreshAgeCollector{
+		desc: prometheus.NewDesc("feeds_last_refresh_age_seconds",
+			"Seconds since the last successful refresh of a feed.",
+			[]string{"feed"}, nil),
+		times: make(map[string]time.Time),
+	}
+)
+
+func init() {
+	prometheus.MustRegister(refreshTimes)
+}
+
+// refreshAgeCollector reports the age of the last successful refresh of
+// every feed at the time of the scrape
+type refreshAgeCollector struct {
+	desc *prometheus.Desc
+
+	mu    sync.Mutex
+	times map[string]time.Time
+}
+
+func (c *refreshAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *refreshAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for feed, t := range c.times {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue,
+			time.Since(t).Seconds(), feed)
+	}
+}
+
+func (c *refreshAgeCollector) Set(feed string, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.times[feed] = t
+}
+
+// ObserveUpstream records the outcome and latency of an upstream API call
+// of source which started at start
+func ObserveUpstream(source string, call string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	upstreamRequests.WithLabelValues(source, call, result).Inc()
+	upstreamDuration.WithLabelValues(source, call).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCacheLookup records a lookup in a source cache
+func ObserveCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// SetCacheSize records the number of items in a source cache
+func SetCacheSize(cache string, size int) {
+	cacheSize.WithLabelValues(cache).Set(float64(size))
+}
+
+// statusRecorder keeps the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func observeFeedRequest(feed string, format string, status int) {
+	feedRequests.WithLabelValues(feed, format, strconv.Itoa(status)).Inc()
+}
@@ -0,0 +1,63 @@
+package feedkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("Upstream", func(t *testing.T) {
+		ok := upstreamRequests.WithLabelValues("test", "call", "success")
+		failed := upstreamRequests.WithLabelValues("test", "call", "error")
+		okBefore, failedBefore := testutil.ToFloat64(ok), testutil.ToFloat64(failed)
+
+		ObserveUpstream("test", "call", time.Now(), nil)
+		ObserveUpstream("test", "call", time.Now(), errors.New("failed"))
+		assert.Equal(t, okBefore+1, testutil.ToFloat64(ok))
+		assert.Equal(t, failedBefore+1, testutil.ToFloat64(failed))
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		hits := cacheLookups.WithLabelValues("test", "hit")
+		before := testutil.ToFloat64(hits)
+		ObserveCacheLookup("test", true)
+		ObserveCacheLookup("test", false)
+		assert.Equal(t, before+1, testutil.ToFloat64(hits))
+
+		SetCacheSize("test", 42)
+		assert.Equal(t, 42.0, testutil.ToFloat64(cacheSize.WithLabelValues("test")))
+	})
+
+	t.Run("FeedRequests", func(t *testing.T) {
+		source := &mockSource{Items: []Item{{ID: "1", Title: "One"}}}
+		feed := NewFeed("/metrics-test", source, NewFeedConfig())
+		okRSS := feedRequests.WithLabelValues("/metrics-test", "rss", "200")
+		badFormat := feedRequests.WithLabelValues("/metrics-test", "unknown", "400")
+
+		for _, target := range []string{"/?format=rss", "/?format=html"} {
+			feed.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+		}
+		assert.Equal(t, 1.0, testutil.ToFloat64(okRSS))
+		assert.Equal(t, 1.0, testutil.ToFloat64(badFormat))
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		server := newTestServer()
+		feed := server.Mount("/age", &mockSource{}, time.Minute)
+		if _, err := feed.Refresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		server.mux.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `feeds_last_refresh_age_seconds{feed="/age"}`)
+	})
+}
@@ -0,0 +1,194 @@
+package feedkit
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RewriteRule rewrites the links of feed items matching Pattern, e.g. to
+// point them to an alternative frontend of the site
+type RewriteRule struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"` // Regexp matched against the link
+	Replace string `yaml:"replace"` // New link, with $1 or ${name} for submatches
+
+	// Optional conditions
+	Feeds  []string `yaml:"feeds,omitempty"`  // Feed name prefixes, e.g. "/hackernews"
+	Unless string   `yaml:"unless,omitempty"` // Regexp of links left alone
+}
+
+// DefaultRewriteRules point Twitter links to a Nitter instance, which
+// shows whole threads without a login
+var DefaultRewriteRules = []RewriteRule{
+	{
+		Name:    "twitter",
+		Pattern: `^https://(?:twitter|x)\.com/(.*)`,
+		Replace: "https://nitter.net/$1",
+	},
+}
+
+type rewriteRule struct {
+	RewriteRule
+	pattern *regexp.Regexp
+	unless  *regexp.Regexp
+}
+
+func (r rewriteRule) match(feed string, link string) bool {
+	if len(r.Feeds) > 0 {
+		found := false
+		for _, prefix := range r.Feeds {
+			found = found || strings.HasPrefix(feed, prefix)
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.unless != nil && r.unless.MatchString(link) {
+		return false
+	}
+	return r.pattern.MatchString(link)
+}
+
+// Rewriter applies rewrite rules to the links of feed items. The first
+// rule matching a link rewrites it; the others are not tried.
+type Rewriter struct {
+	rules []rewriteRule
+}
+
+func NewRewriter(rules []RewriteRule) (*Rewriter, error) {
+	r := &Rewriter{}
+	for idx, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", idx+1)
+		}
+		compiled := rewriteRule{RewriteRule: rule}
+		var err error
+		if compiled.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern: %v", rule.Name, err)
+		}
+		if rule.Unless != "" {
+			if compiled.unless, err = regexp.Compile(rule.Unless); err != nil {
+				return nil, fmt.Errorf("%s: invalid unless pattern: %v", rule.Name, err)
+			}
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+// Rewrite returns the link rewritten by the first matching rule of the
+// feed, and the name of the rule, or the link and "" if no rule matches
+func (r *Rewriter) Rewrite(feed string, link string) (string, string) {
+	if r == nil {
+		return link, ""
+	}
+	for _, rule := range r.rules {
+		if rule.match(feed, link) {
+			return rule.pattern.ReplaceAllString(link, rule.Replace), rule.Name
+		}
+	}
+	return link, ""
+}
+
+// Apply rewrites the links of the items of a feed. Items are copied before
+// they are rewritten, so sources can keep the items they return.
+func (r *Rewriter) Apply(feed string, items []Item) []Item {
+	if r == nil || len(r.rules) == 0 {
+		return items
+	}
+	rewritten := make([]Item, len(items))
+	for idx, item := range items {
+		item.Link, _ = r.Rewrite(feed, item.Link)
+		rewritten[idx] = item
+	}
+	return rewritten
+}
+
+func validateRewriteRules(rules []RewriteRule, prefix string) []string {
+	var problems []string
+	for idx, rule := range rules {
+		if rule.Pattern == "" || rule.Replace == "" {
+			problems = append(problems,
+				fmt.Sprintf("%srewrites[%d]: pattern and replace must be set", prefix, idx))
+		}
+	}
+	if _, err := NewRewriter(rules); err != nil {
+		problems = append(problems, prefix+"rewrites: "+err.Error())
+	}
+	return problems
+}
+
+// urlList collects the values of a repeated flag. LoadConfig parses flags
+// twice, so values already in the list are skipped.
+type urlList []string
+
+func (l *urlList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *urlList) Set(value string) error {
+	for _, v := range *l {
+		if v == value {
+			return nil
+		}
+	}
+	*l = append(*l, value)
+	return nil
+}
+
+// rewriteCommand adds the flags of the rewrite subcommand to the config of
+// a server
+type rewriteCommand struct {
+	Configurable
+	URLs urlList
+	Feed string
+}
+
+func (c *rewriteCommand) Flags(fs *flag.FlagSet, prefix string) {
+	c.Configurable.Flags(fs, prefix)
+	fs.Var(&c.URLs, "url", "URL to rewrite, may be repeated")
+	fs.StringVar(&c.Feed, "feed", "", "Name of the feed the URLs are in, e.g. /hackernews/top")
+}
+
+func (c *rewriteCommand) Validate(prefix string) []string {
+	problems := c.Configurable.Validate(prefix)
+	if len(c.URLs) == 0 {
+		problems = append(problems, "url: must be set")
+	}
+	return problems
+}
+
+// UnmarshalYAML decodes the config file into the config of the server
+func (c *rewriteCommand) UnmarshalYAML(node *yaml.Node) error {
+	return node.Decode(c.Configurable)
+}
+
+// RunRewrite runs the rewrite subcommand of a server, which loads config
+// like LoadConfig and prints how the rewrite rules of server, part of
+// config, rewrite the URLs given with -url, e.g.
+//
+//	hackernews rewrite -config feeds.yaml -url https://twitter.com/jack
+func RunRewrite(w io.Writer, name string, args []string, config Configurable, server *ServerConfig) error {
+	command := &rewriteCommand{Configurable: config}
+	if _, err := LoadConfig(name+" rewrite", args, command); err != nil {
+		return err
+	}
+	rewriter, err := NewRewriter(server.Rewrites)
+	if err != nil {
+		return err
+	}
+	for _, link := range command.URLs {
+		rewritten, rule := rewriter.Rewrite(command.Feed, link)
+		if rule == "" {
+			fmt.Fprintf(w, "%s: no rule matches\n", link)
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s (%s)\n", link, rewritten, rule)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package feedkit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules of the README example
+const exampleRules = `
+- name: reddit
+  pattern: ^https://(?:www\.)?reddit\.com/(.*)
+  replace: https://old.reddit.com/$1
+- name: twitter
+  pattern: ^https://(?:twitter|x)\.com/(.*)
+  replace: https://nitter.privacydev.net/$1
+  feeds: [/hackernews]
+- name: youtube
+  pattern: ^https://(?:www\.|m\.)?youtube\.com/watch\?(.*)
+  replace: https://yewtu.be/watch?$1
+- name: youtu.be
+  pattern: ^https://youtu\.be/([\w-]+)
+  replace: https://yewtu.be/watch?v=$1
+- name: medium
+  pattern: ^https://(?:[\w-]+\.)?medium\.com/(.*)
+  replace: https://scribe.rip/$1
+  unless: ^https://medium\.com/?$
+`
+
+func newExampleRewriter(t *testing.T) *Rewriter {
+	var rules []RewriteRule
+	if err := yaml.Unmarshal([]byte(exampleRules), &rules); err != nil {
+		t.Fatal(err)
+	}
+	rewriter, err := NewRewriter(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rewriter
+}
+
+func TestRewriter(t *testing.T) {
+	rewriter := newExampleRewriter(t)
+	tests := []struct {
+		Name string
+		Feed string
+		Link string
+		Want string
+		Rule string
+	}{
+		{"Reddit", "/top", "https://www.reddit.com/r/golang/comments/1",
+			"https://old.reddit.com/r/golang/comments/1", "reddit"},
+		{"Twitter", "/hackernews/top", "https://twitter.com/jack/status/20",
+			"https://nitter.privacydev.net/jack/status/20", "twitter"},
+		{"X", "/hackernews/item", "https://x.com/jack/status/20",
+			"https://nitter.privacydev.net/jack/status/20", "twitter"},
+		{"OtherFeed", "/atlasobscura", "https://twitter.com/jack/status/20",
+			"https://twitter.com/jack/status/20", ""},
+		{"YouTube", "/top", "https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=1",
+			"https://yewtu.be/watch?v=dQw4w9WgXcQ&t=1", "youtube"},
+		{"YoutuBe", "/top", "https://youtu.be/dQw4w9WgXcQ",
+			"https://yewtu.be/watch?v=dQw4w9WgXcQ", "youtu.be"},
+		{"Medium", "/top", "https://blog.medium.com/some-post-123",
+			"https://scribe.rip/some-post-123", "medium"},
+		{"Unless", "/top", "https://medium.com/", "https://medium.com/", ""},
+		{"NoMatch", "/top", "https://example.com/", "https://example.com/", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			link, rule := rewriter.Rewrite(test.Feed, test.Link)
+			assert.Equal(t, test.Want, link)
+			assert.Equal(t, test.Rule, rule)
+		})
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		rewriter, err := NewRewriter(DefaultRewriteRules)
+		assert.Nil(t, err)
+		link, _ := rewriter.Rewrite("/top", "https://twitter.com/BrantlyMillegan/status/1402388133086367751")
+		assert.Equal(t, "https://nitter.net/BrantlyMillegan/status/1402388133086367751", link)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var rewriter *Rewriter
+		link, rule := rewriter.Rewrite("/top", "https://twitter.com/jack")
+		assert.Equal(t, "https://twitter.com/jack", link)
+		assert.Equal(t, "", rule)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewRewriter([]RewriteRule{{Name: "bad", Pattern: "(", Replace: "x"}})
+		assert.NotNil(t, err)
+		problems := validateRewriteRules([]RewriteRule{{Pattern: "^https://"}}, "")
+		assert.Len(t, problems, 1)
+	})
+}
+
+func TestFeedRewrite(t *testing.T) {
+	items := []Item{
+		{ID: "1", Title: "Thread", Link: "https://twitter.com/jack/status/20"},
+		{ID: "2", Title: "Post", Link: "https://example.com/post"},
+	}
+	source := &mockSource{Items: items}
+	config := NewFeedConfig()
+	config.Rewriter = newExampleRewriter(t)
+	feed := NewFeed("/hackernews/top", source, config)
+
+	snapshot, err := feed.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://nitter.privacydev.net/jack/status/20", snapshot.Items[0].Link)
+	assert.Equal(t, "https://example.com/post", snapshot.Items[1].Link)
+	assert.Contains(t, string(snapshot.Bodies[FormatAtom].Data), "https://nitter.privacydev.net/jack/status/20")
+	// The items of the source are left alone
+	assert.Equal(t, "https://twitter.com/jack/status/20", items[0].Link)
+}
+
+func TestRunRewrite(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  rewrites:\n"+indent(exampleRules, "    "))
+
+	config := newMockConfig()
+	var out bytes.Buffer
+	err := RunRewrite(&out, "test", []string{
+		"-config", path,
+		"-feed", "/hackernews/best",
+		"-url", "https://x.com/jack/status/20",
+		"--url", "https://example.com/",
+	}, config, &config.Server)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://x.com/jack/status/20: https://nitter.privacydev.net/jack/status/20 (twitter)\n"+
+		"https://example.com/: no rule matches\n", out.String())
+
+	err = RunRewrite(&out, "test", nil, config, &config.Server)
+	assert.NotNil(t, err, "-url is required")
+}
+
+func indent(text string, prefix string) string {
+	var b bytes.Buffer
+	for _, line := range bytes.Split([]byte(text), []byte("\n")) {
+		if len(line) > 0 {
+			b.WriteString(prefix)
+			b.Write(line)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,227 @@
+package feedkit
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	DefaultAddr    = ":8080"
+	DefaultTimeout = 10 * time.Second
+)
+
+type mount struct {
+	Feed            *Feed
+	RefreshInterval time.Duration
+}
+
+type task struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Server mounts feeds on their paths and keeps them refreshed
+type Server struct {
+	Addr       string
+	Timeout    time.Duration
+	StaleAfter time.Duration // Readiness threshold, see staleAfter
+	FeedConfig FeedConfig
+
+	mux           *http.ServeMux
+	mounts        []mount
+	tasks         []task
+	shutdownHooks []func() error
+}
+
+func NewServer(config ServerConfig) *Server {
+	feedConfig := NewFeedConfig()
+	feedConfig.Author = config.Author
+	feedConfig.AuthorEmail = config.AuthorEmail
+	// Rules are checked when the config is validated
+	rewriter, err := NewRewriter(config.Rewrites)
+	if err != nil {
+		log.Print("Ignoring rewrite rules: ", err)
+	}
+	feedConfig.Rewriter = rewriter
+
+	s := &Server{
+		Addr:       config.Addr,
+		Timeout:    config.Timeout,
+		StaleAfter: config.StaleAfter,
+		FeedConfig: feedConfig,
+		mux:        http.NewServeMux(),
+	}
+	s.Handle("/diagnostics", feedConfig.Diagnostics.Handler())
+	s.Handle("/metrics", promhttp.Handler())
+	s.Handle("/healthz", http.HandlerFunc(healthzHandler))
+	s.Handle("/readyz", http.HandlerFunc(s.readyzHandler))
+	return s
+}
+
+// Mount serves the feed of source on path, refreshing it every interval
+func (s *Server) Mount(path string, source Source, interval time.Duration) *Feed {
+	feed := NewFeed(path, source, s.FeedConfig)
+	s.mounts = append(s.mounts, mount{feed, interval})
+	s.Handle(path, feed)
+	return feed
+}
+
+// MountSet serves the feeds of sources built on demand from the request
+// paths below prefix, refreshing each feed on request once it is older
+// than maxAge
+func (s *Server) MountSet(prefix string, newSource NewSourceFunc, maxAge time.Duration) *FeedSet {
+	set := NewFeedSet(prefix, maxAge, s.FeedConfig, newSource)
+	s.Handle(prefix, http.StripPrefix(prefix, set))
+	return set
+}
+
+func (s *Server) Handle(path string, handler http.Handler) {
+	s.mux.Handle(path, handler)
+}
+
+// Every runs f every interval while the server runs, e.g. to keep the
+// cache of a source up to date. Like refreshes, each run gets a context
+// which expires with the interval.
+func (s *Server) Every(name string, interval time.Duration, f func(ctx context.Context) error) {
+	s.tasks = append(s.tasks, task{name, interval, f})
+}
+
+// OnShutdown registers a function to be called once the server has shut
+// down, e.g. to flush caches to the store
+func (s *Server) OnShutdown(f func() error) {
+	s.shutdownHooks = append(s.shutdownHooks, f)
+}
+
+// refreshFeed refreshes a feed with a context of its own, so that a
+// refresh never outlives its interval
+func (s *Server) refreshFeed(ctx context.Context, m mount) {
+	ctx, cancel := context.WithTimeout(ctx, m.RefreshInterval)
+	defer cancel()
+	m.Feed.Refresh(ctx)
+}
+
+func (s *Server) runTask(ctx context.Context, t task) {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runCtx, cancel := context.WithTimeout(ctx, t.Interval)
+			if err := t.Run(runCtx); err != nil {
+				log.Printf("Failed to run %s: %v", t.Name, err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (s *Server) refresh(ctx context.Context, m mount) {
+	ticker := time.NewTicker(m.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshFeed(ctx, m)
+		}
+	}
+}
+
+// Run serves the mounted feeds until ctx is cancelled. Every feed is
+// fetched in the background once the server listens, so that /healthz
+// answers and /readyz reports the feeds which are not refreshed yet during
+// a slow start. The server then stops refreshing feeds, drains open
+// connections and runs the shutdown hooks.
+func (s *Server) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	log.Print("Starting server")
+	srv := http.Server{
+		Addr:         s.Addr,
+		ReadTimeout:  s.Timeout / 2.0,
+		WriteTimeout: s.Timeout,
+		Handler:      http.TimeoutHandler(s.mux, s.Timeout, "Timeout!\n"),
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	var wg sync.WaitGroup
+	for _, m := range s.mounts {
+		wg.Add(1)
+		go func(m mount) {
+			defer wg.Done()
+			// Cache feeds at startup
+			if err := m.Feed.Load(ctx); err != nil {
+				log.Printf("Failed to load %s feed: %v", m.Feed.Name, err)
+			}
+			s.refreshFeed(ctx, m)
+			s.refresh(ctx, m)
+		}(m)
+	}
+	for _, t := range s.tasks {
+		wg.Add(1)
+		go func(t task) {
+			defer wg.Done()
+			s.runTask(ctx, t)
+		}(t)
+	}
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		log.Print("Shutting down server")
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), s.Timeout)
+		defer cancelShutdown()
+		err = srv.Shutdown(shutdownCtx)
+	}
+
+	cancel()
+	wg.Wait()
+	for _, f := range s.shutdownHooks {
+		if hookErr := f(); hookErr != nil {
+			log.Print("Shutdown hook failed: ", hookErr)
+		}
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// SignalContext returns a context which is cancelled on SIGINT or SIGTERM
+func SignalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Print("Received signal: ", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
@@ -0,0 +1,164 @@
+package feedkit
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingSource blocks every fetch after the first one until its context
+// is done
+type blockingSource struct {
+	mockSource
+	Cancelled chan error
+}
+
+func (s *blockingSource) Fetch(ctx context.Context) ([]Item, []ItemError, error) {
+	if s.Fetches == 0 {
+		return s.mockSource.Fetch(ctx)
+	}
+	s.Fetches++
+	<-ctx.Done()
+	s.Cancelled <- ctx.Err()
+	return nil, nil, ctx.Err()
+}
+
+// slowSource blocks its fetches until Release is closed
+type slowSource struct {
+	mockSource
+	Release chan struct{}
+}
+
+func (s *slowSource) Fetch(ctx context.Context) ([]Item, []ItemError, error) {
+	select {
+	case <-s.Release:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	return s.mockSource.Fetch(ctx)
+}
+
+func newTestServer() *Server {
+	config := DefaultServerConfig()
+	config.Addr = "127.0.0.1:0"
+	config.Timeout = time.Second
+	return NewServer(config)
+}
+
+func TestServerRun(t *testing.T) {
+	t.Run("Shutdown", func(t *testing.T) {
+		server := newTestServer()
+		source := &mockSource{Items: []Item{{ID: "1", Title: "One"}}}
+		server.Mount("/mock", source, 10*time.Millisecond)
+		runs := make(chan struct{}, 100)
+		server.Every("task", 10*time.Millisecond, func(ctx context.Context) error {
+			runs <- struct{}{}
+			return nil
+		})
+		flushed := false
+		server.OnShutdown(func() error {
+			flushed = true
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		assert.Nil(t, server.Run(ctx))
+		assert.True(t, flushed)
+		assert.Greater(t, source.Fetches, 1)
+		assert.Greater(t, len(runs), 1)
+	})
+
+	t.Run("RefreshContext", func(t *testing.T) {
+		server := newTestServer()
+		source := &blockingSource{Cancelled: make(chan error, 10)}
+		server.Mount("/mock", source, 20*time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- server.Run(ctx)
+		}()
+
+		// A stuck refresh is cancelled once its interval is over
+		select {
+		case err := <-source.Cancelled:
+			assert.Equal(t, context.DeadlineExceeded, err)
+		case <-time.After(time.Second):
+			t.Fatal("Refresh not cancelled")
+		}
+
+		cancel()
+		select {
+		case err := <-done:
+			assert.Nil(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("Server did not shut down")
+		}
+	})
+
+	t.Run("SlowStart", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := listener.Addr().String()
+		listener.Close()
+
+		server := newTestServer()
+		server.Addr = addr
+		source := &slowSource{Release: make(chan struct{})}
+		server.Mount("/mock", source, time.Minute)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- server.Run(ctx)
+		}()
+
+		get := func(path string) (int, string) {
+			var resp *http.Response
+			var err error
+			for i := 0; i < 50; i++ {
+				if resp, err = http.Get("http://" + addr + path); err == nil {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			return resp.StatusCode, string(body)
+		}
+
+		// The health endpoints are served while the first refresh runs
+		code, _ := get("/healthz")
+		assert.Equal(t, http.StatusOK, code)
+		code, body := get("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Contains(t, body, "not refreshed yet")
+
+		close(source.Release)
+		for i := 0; i < 50 && code != http.StatusOK; i++ {
+			time.Sleep(10 * time.Millisecond)
+			code, _ = get("/readyz")
+		}
+		assert.Equal(t, http.StatusOK, code)
+
+		cancel()
+		assert.Nil(t, <-done)
+	})
+
+	t.Run("ListenFailure", func(t *testing.T) {
+		server := newTestServer()
+		server.Addr = "256.0.0.1:0"
+		assert.NotNil(t, server.Run(context.Background()))
+	})
+}
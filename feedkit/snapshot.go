@@ -0,0 +1,163 @@
+package feedkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeedBody is a rendered feed along with its gzipped form and entity tag
+type FeedBody struct {
+	Data []byte
+	Gzip []byte
+	ETag string
+}
+
+// FeedSnapshot holds a feed pre-rendered in every supported format.
+// The version is bumped whenever the feed contents change.
+type FeedSnapshot struct {
+	Version  int
+	Checksum string // Checksum of the items the feed was built from
+	Modified time.Time
+	Bodies   map[FeedFormat]FeedBody
+	Items    []Item
+}
+
+// newFeedBody gzips a rendered feed and computes its entity tag
+func newFeedBody(data []byte) (FeedBody, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return FeedBody{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return FeedBody{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	return FeedBody{
+		Data: data,
+		Gzip: buf.Bytes(),
+		ETag: `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}, nil
+}
+
+// GzipETag is the entity tag of the gzip-encoded representation
+func (b FeedBody) GzipETag() string {
+	return strings.TrimSuffix(b.ETag, `"`) + `-gzip"`
+}
+
+// newFeedSnapshot renders a feed in every supported format
+func newFeedSnapshot(feed *document, checksum string, version int) (*FeedSnapshot, error) {
+	snapshot := &FeedSnapshot{
+		Version:  version,
+		Checksum: checksum,
+		Modified: feed.Created,
+		Bodies:   make(map[FeedFormat]FeedBody),
+	}
+	for _, format := range FeedFormats {
+		data, err := renderFeed(feed, format)
+		if err != nil {
+			return nil, err
+		}
+		body, err := newFeedBody([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Bodies[format] = body
+	}
+	return snapshot, nil
+}
+
+// checksum returns a digest of the JSON encoding of v, used to detect
+// whether a feed needs to be rebuilt
+func checksum(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func acceptsGzip(req *http.Request) bool {
+	for _, part := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		if strings.TrimSpace(fields[0]) != "gzip" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.ReplaceAll(param, " ", "")
+			if q := strings.TrimPrefix(param, "q="); q != param {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// notModified evaluates the conditional request headers. As required for
+// If-None-Match, entity tags are compared weakly, so either representation
+// of the body matches.
+func notModified(req *http.Request, body FeedBody, modified time.Time) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == body.ETag || tag == body.GzipETag() {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !modified.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
+
+// serveFeedBody writes a feed body, honouring conditional requests and
+// gzip content encoding
+func serveFeedBody(w http.ResponseWriter, req *http.Request, body FeedBody, modified time.Time, format FeedFormat) {
+	header := w.Header()
+	header.Set("Content-Type", format.ContentType())
+	header.Set("Vary", "Accept, Accept-Encoding")
+	if !modified.IsZero() {
+		header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	data, etag := body.Data, body.ETag
+	if acceptsGzip(req) {
+		data, etag = body.Gzip, body.GzipETag()
+		header.Set("Content-Encoding", "gzip")
+	}
+	header.Set("ETag", etag)
+
+	if notModified(req, body, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// Serve writes the snapshot in the given format, honouring conditional
+// requests and gzip content encoding
+func (s *FeedSnapshot) Serve(w http.ResponseWriter, req *http.Request, format FeedFormat) {
+	serveFeedBody(w, req, s.Bodies[format], s.Modified, format)
+}
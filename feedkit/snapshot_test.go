@@ -0,0 +1,88 @@
+package feedkit
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedSnapshot(t *testing.T) {
+	modified := time.Date(2021, time.May, 25, 10, 29, 48, 0, time.UTC)
+	feed := &feeds.Feed{
+		Title:   "Hacker News",
+		Link:    &feeds.Link{Href: "https://news.ycombinator.com/best"},
+		Created: modified,
+	}
+	feed.Add(&feeds.Item{
+		Title:   "Writing Pythonic Rust",
+		Link:    &feeds.Link{Href: "http://www.cmyr.net/blog/rust-python-learnings.html"},
+		Id:      "https://news.ycombinator.com/item?id=27267066",
+		Created: modified.Add(-time.Hour),
+	})
+
+	doc := &document{Feed: feed, Categories: [][]string{nil}}
+	snapshot, err := newFeedSnapshot(doc, checksum(feed.Items), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := snapshot.Bodies[FormatAtom]
+
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		snapshot.Serve(rr, req, FormatAtom)
+		return rr
+	}
+
+	t.Run("Validators", func(t *testing.T) {
+		rr := serve(nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, body.ETag, rr.Header().Get("ETag"))
+		assert.Equal(t, "Tue, 25 May 2021 10:29:48 GMT", rr.Header().Get("Last-Modified"))
+		assert.Equal(t, string(body.Data), rr.Body.String())
+	})
+
+	t.Run("IfNoneMatch", func(t *testing.T) {
+		rr := serve(http.Header{"If-None-Match": {`"stale", ` + body.ETag}})
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Empty(t, rr.Body.String())
+
+		rr = serve(http.Header{"If-None-Match": {`"stale"`}})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("IfModifiedSince", func(t *testing.T) {
+		rr := serve(http.Header{"If-Modified-Since": {"Tue, 25 May 2021 10:29:48 GMT"}})
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+
+		rr = serve(http.Header{"If-Modified-Since": {"Tue, 25 May 2021 10:00:00 GMT"}})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Gzip", func(t *testing.T) {
+		rr := serve(http.Header{"Accept-Encoding": {"deflate, gzip"}})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, body.GzipETag(), rr.Header().Get("ETag"))
+
+		zr, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(zr)
+		assert.Nil(t, err)
+		assert.Equal(t, body.Data, data)
+
+		rr = serve(http.Header{"Accept-Encoding": {"gzip;q=0"}})
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	})
+}
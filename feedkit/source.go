@@ -0,0 +1,69 @@
+// Package feedkit serves feeds built from pluggable sources.
+//
+// A Source fetches the items of a feed. The server mounts each source on
+// its own path, refreshes it in the background and serves pre-rendered
+// Atom, RSS and JSON Feed snapshots of it.
+package feedkit
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// Item is a single feed entry
+type Item struct {
+	ID          string
+	Title       string
+	Link        string
+	Source      string // Link to the item on the site it was found on
+	Description string
+	Content     string
+	Created     time.Time
+	Author      string   // Overrides the feed author
+	Categories  []string // Terms readers can filter entries by
+	Enclosure   *Enclosure
+
+	// Data is the source specific record the item was built from, used
+	// by sources to filter items
+	Data interface{}
+}
+
+// Enclosure is a file attached to an item, e.g. an image
+type Enclosure struct {
+	URL    string
+	Type   string // MIME type, e.g. "image/jpeg"
+	Length int64  // Size in bytes, 0 if unknown
+}
+
+// Metadata describes a feed
+type Metadata struct {
+	Title       string
+	Link        string
+	Description string
+}
+
+// Source is the upstream of a feed
+type Source interface {
+	Metadata() Metadata
+	// Fetch returns the current items of the feed, newest first, along
+	// with the items which could not be fetched
+	Fetch(ctx context.Context) ([]Item, []ItemError, error)
+}
+
+// ItemFilter reports whether an item should be included in a feed
+type ItemFilter func(Item) bool
+
+// Filterer is implemented by sources whose feeds can be narrowed down with
+// query parameters
+type Filterer interface {
+	// Filter parses the query parameters of a feed request. It returns
+	// a nil filter if the parameters do not filter the feed.
+	Filter(params url.Values) (ItemFilter, error)
+}
+
+// Loader is implemented by sources which persist their items, so that
+// feeds can be served from the store before they are first fetched
+type Loader interface {
+	Load(ctx context.Context) ([]Item, error)
+}
@@ -0,0 +1,165 @@
+package feedkit
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store persists feed records across restarts. Records are grouped in
+// named buckets and saved as JSON documents.
+type Store interface {
+	// Put saves records, keyed by their IDs, in a bucket
+	Put(bucket string, records map[string]interface{}) error
+	// ForEach calls fn with the key and JSON document of every record
+	// in a bucket
+	ForEach(bucket string, fn func(key string, data []byte) error) error
+	Delete(bucket string, keys ...string) error
+	Close() error
+}
+
+// OpenStore opens the on-disk store at path, falling back to a store which
+// only lives as long as the process if the path is empty
+func OpenStore(path string) (Store, error) {
+	if path == "" {
+		log.Print("No store path set, feed history will not be persisted")
+		return NewMemoryStore(), nil
+	}
+	return NewBoltStore(path)
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+func NewMemoryStore() *memoryStore {
+	return &memoryStore{
+		buckets: make(map[string]map[string][]byte),
+	}
+}
+
+func (s *memoryStore) Put(bucket string, records map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[bucket]
+	if !ok {
+		b = make(map[string][]byte)
+		s.buckets[bucket] = b
+	}
+	for key, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		b[key] = data
+	}
+	return nil
+}
+
+func (s *memoryStore) ForEach(bucket string, fn func(key string, data []byte) error) error {
+	s.mu.Lock()
+	b := s.buckets[bucket]
+	keys := make([]string, 0, len(b))
+	for key := range b {
+		keys = append(keys, key)
+	}
+	records := make(map[string][]byte, len(b))
+	for key, data := range b {
+		records[key] = data
+	}
+	s.mu.Unlock()
+
+	// Iterate in key order, like the on-disk store
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, records[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Delete(bucket string, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.buckets[bucket], key)
+	}
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+type boltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Put(bucket string, records map[string]interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		for key, record := range records {
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) ForEach(bucket string, fn func(key string, data []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			// Values are only valid for the duration of the transaction
+			data := make([]byte, len(v))
+			copy(data, v)
+			return fn(string(k), data)
+		})
+	})
+}
+
+func (s *boltStore) Delete(bucket string, keys ...string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		for _, key := range keys {
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,66 @@
+package feedkit
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readBucket(t *testing.T, store Store, bucket string) map[string]string {
+	records := make(map[string]string)
+	err := store.ForEach(bucket, func(key string, data []byte) error {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		records[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func testStore(t *testing.T, store Store) {
+	assert.Empty(t, readBucket(t, store, "missing"))
+	assert.Nil(t, store.Delete("missing", "foo"))
+
+	err := store.Put("bucket", map[string]interface{}{
+		"foo": "one",
+		"bar": "two",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"foo": "one", "bar": "two"},
+		readBucket(t, store, "bucket"))
+
+	assert.Nil(t, store.Put("bucket", map[string]interface{}{"foo": "three"}))
+	assert.Nil(t, store.Delete("bucket", "bar"))
+	assert.Equal(t, map[string]string{"foo": "three"},
+		readBucket(t, store, "bucket"))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feeds.db")
+	store, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+	assert.Nil(t, store.Close())
+
+	// Records survive reopening the store
+	store, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	assert.Equal(t, map[string]string{"foo": "three"},
+		readBucket(t, store, "bucket"))
+}
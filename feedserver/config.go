@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"duh-uh.com/app/rss-feeds/hackernews"
+	"duh-uh.com/app/twitterrss/atlasobscura"
+)
+
+// sourceList is a comma-separated list of source names
+type sourceList []string
+
+func (l *sourceList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *sourceList) Set(value string) error {
+	*l = nil
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*l = append(*l, name)
+		}
+	}
+	return nil
+}
+
+type config struct {
+	Sources      sourceList           `yaml:"sources"`
+	Server       feedkit.ServerConfig `yaml:"server"`
+	HackerNews   hackernews.Config    `yaml:"hackernews"`
+	AtlasObscura atlasobscura.Config  `yaml:"atlasobscura"`
+}
+
+func defaultConfig() *config {
+	return &config{
+		Sources:      sourceList{"hackernews", "atlasobscura"},
+		Server:       feedkit.DefaultServerConfig(),
+		HackerNews:   hackernews.DefaultConfig(),
+		AtlasObscura: atlasobscura.DefaultConfig(),
+	}
+}
+
+func (c *config) Flags(fs *flag.FlagSet, prefix string) {
+	fs.Var(&c.Sources, prefix+"sources", "Comma-separated list of sources to serve")
+	c.Server.Flags(fs, prefix)
+	c.HackerNews.Flags(fs, prefix+"hackernews.")
+	c.AtlasObscura.Flags(fs, prefix+"atlasobscura.")
+}
+
+func (c *config) Validate(prefix string) []string {
+	var problems []string
+	if len(c.Sources) == 0 {
+		problems = append(problems, prefix+"sources: must not be empty")
+	}
+	for _, name := range c.Sources {
+		if _, ok := sources[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%ssources: unknown source %q", prefix, name))
+		}
+	}
+	problems = append(problems, c.Server.Validate(prefix)...)
+	problems = append(problems, c.HackerNews.Validate(prefix+"hackernews.")...)
+	problems = append(problems, c.AtlasObscura.Validate(prefix+"atlasobscura.")...)
+	return problems
+}
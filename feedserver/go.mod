@@ -0,0 +1,15 @@
+module duh-uh.com/app/rss-feeds/feedserver
+
+go 1.15
+
+require (
+	duh-uh.com/app/rss-feeds/feedkit v0.0.0
+	duh-uh.com/app/rss-feeds/hackernews v0.0.0
+	duh-uh.com/app/twitterrss/atlasobscura v0.0.0
+)
+
+replace (
+	duh-uh.com/app/rss-feeds/feedkit => ../feedkit
+	duh-uh.com/app/rss-feeds/hackernews => ../hackernews
+	duh-uh.com/app/twitterrss/atlasobscura => ../atlasobscura
+)
@@ -0,0 +1,67 @@
+// Command feedserver serves the feeds of all sources from a single binary.
+//
+// Each source is mounted under its own path prefix, e.g. /hackernews/best
+// and /atlasobscura.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"duh-uh.com/app/rss-feeds/hackernews"
+	"duh-uh.com/app/twitterrss/atlasobscura"
+)
+
+// mounter mounts the feeds of a source on the server
+type mounter func(server *feedkit.Server, cfg *config, store feedkit.Store) error
+
+var sources = map[string]mounter{
+	"hackernews": func(server *feedkit.Server, cfg *config, store feedkit.Store) error {
+		hackernews.Mount(server, "/hackernews", hackernews.DefaultAPI(), cfg.HackerNews, store)
+		return nil
+	},
+	"atlasobscura": func(server *feedkit.Server, cfg *config, store feedkit.Store) error {
+		return atlasobscura.Mount(server, "/atlasobscura", cfg.AtlasObscura, store)
+	},
+}
+
+func run() error {
+	cfg := defaultConfig()
+	if len(os.Args) > 1 && os.Args[1] == "rewrite" {
+		return feedkit.RunRewrite(os.Stdout, "feedserver", os.Args[2:], cfg, &cfg.Server)
+	}
+	printConfig, err := feedkit.LoadConfig("feedserver", os.Args[1:], cfg)
+	if err != nil {
+		return err
+	}
+	if printConfig {
+		return feedkit.PrintConfig(os.Stdout, cfg)
+	}
+
+	store, err := feedkit.OpenStore(cfg.Server.StorePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	server := feedkit.NewServer(cfg.Server)
+	for _, name := range cfg.Sources {
+		if err := sources[name](server, cfg, store); err != nil {
+			return fmt.Errorf("failed to mount %s: %v", name, err)
+		}
+	}
+
+	ctx, stop := feedkit.SignalContext(context.Background())
+	defer stop()
+	return server.Run(ctx)
+}
+
+func main() {
+	// Fail from run, so that the store is closed before exiting
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
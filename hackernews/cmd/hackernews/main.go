@@ -0,0 +1,64 @@
+// Command hackernews serves feeds of the Hacker News story lists.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"duh-uh.com/app/rss-feeds/hackernews"
+)
+
+type config struct {
+	Server     feedkit.ServerConfig `yaml:"server"`
+	HackerNews hackernews.Config    `yaml:"hackernews"`
+}
+
+func (c *config) Flags(fs *flag.FlagSet, prefix string) {
+	c.Server.Flags(fs, prefix)
+	c.HackerNews.Flags(fs, prefix+"hackernews.")
+}
+
+func (c *config) Validate(prefix string) []string {
+	return append(c.Server.Validate(prefix), c.HackerNews.Validate(prefix+"hackernews.")...)
+}
+
+func run() error {
+	cfg := &config{
+		Server:     feedkit.DefaultServerConfig(),
+		HackerNews: hackernews.DefaultConfig(),
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rewrite" {
+		return feedkit.RunRewrite(os.Stdout, "hackernews", os.Args[2:], cfg, &cfg.Server)
+	}
+	printConfig, err := feedkit.LoadConfig("hackernews", os.Args[1:], cfg)
+	if err != nil {
+		return err
+	}
+	if printConfig {
+		return feedkit.PrintConfig(os.Stdout, cfg)
+	}
+
+	store, err := feedkit.OpenStore(cfg.Server.StorePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	server := feedkit.NewServer(cfg.Server)
+	hackernews.Mount(server, "", hackernews.DefaultAPI(), cfg.HackerNews, store)
+
+	ctx, stop := feedkit.SignalContext(context.Background())
+	defer stop()
+	return server.Run(ctx)
+}
+
+func main() {
+	// Fail from run, so that the store is closed before exiting
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,127 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+)
+
+// commentThread is a comment with the replies to it which are included in
+// a feed entry
+type commentThread struct {
+	Comment Story
+	Replies []*commentThread
+}
+
+// topKids returns the first n replies to an item, which the API lists in
+// ranked order
+func topKids(kids []StoryID, n int) []StoryID {
+	if len(kids) > n {
+		return kids[:n]
+	}
+	return kids
+}
+
+// getComments looks up the top config.Comments comments of each story and
+// of each of their replies, down to config.CommentDepth levels. The threads
+// are rooted at the stories. Each level is looked up at once through the
+// story cache; comments which could not be fetched, were killed or were
+// deleted are skipped, along with their replies.
+func getComments(ctx context.Context, api HackerNewsAPI, config Config, stories []Story, storyCache *cache.Cache) (map[StoryID]*commentThread, []feedkit.ItemError) {
+	threads := make(map[StoryID]*commentThread, len(stories))
+	failures := make([]feedkit.ItemError, 0)
+
+	level := make([]*commentThread, 0, len(stories))
+	for _, story := range stories {
+		thread := &commentThread{Comment: story}
+		threads[story.ID] = thread
+		level = append(level, thread)
+	}
+
+	for depth := 0; depth < config.CommentDepth && len(level) > 0; depth++ {
+		ids := make([]StoryID, 0)
+		for _, parent := range level {
+			ids = append(ids, topKids(parent.Comment.Kids, config.Comments)...)
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		comments, failed := lookupItems(ctx, api, config, ids, storyCache)
+		failures = append(failures, failed...)
+		found := make(map[StoryID]Story, len(comments))
+		for _, comment := range comments {
+			found[comment.ID] = comment
+		}
+
+		next := make([]*commentThread, 0, len(ids))
+		for _, parent := range level {
+			for _, id := range topKids(parent.Comment.Kids, config.Comments) {
+				comment, ok := found[id]
+				if !ok || comment.Removed() {
+					continue
+				}
+				thread := &commentThread{Comment: comment}
+				parent.Replies = append(parent.Replies, thread)
+				next = append(next, thread)
+			}
+		}
+		level = next
+	}
+	return threads, failures
+}
+
+// relativeTime describes a duration in the largest whole unit
+func relativeTime(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case d < time.Minute:
+		return plural(int(d/time.Second), "second")
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+}
+
+// renderComments renders the replies of a story thread as nested HTML
+// lists. Comment times are relative to the story rather than to now, so
+// the entry does not change on every refresh.
+func renderComments(thread *commentThread) string {
+	if thread == nil || len(thread.Replies) == 0 {
+		return ""
+	}
+	story := thread.Comment
+	var b strings.Builder
+	var render func(threads []*commentThread)
+	render = func(threads []*commentThread) {
+		b.WriteString("<ul>")
+		for _, thread := range threads {
+			comment := thread.Comment
+			fmt.Fprintf(&b, `<li><p><b>%s</b> <time datetime="%s">%s after the story</time></p>%s`,
+				html.EscapeString(comment.By),
+				comment.Time().UTC().Format(time.RFC3339),
+				relativeTime(comment.Time().Sub(story.Time())),
+				comment.Text)
+			if len(thread.Replies) > 0 {
+				render(thread.Replies)
+			}
+			b.WriteString("</li>")
+		}
+		b.WriteString("</ul>")
+	}
+	render(thread.Replies)
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package hackernews
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// itemServer is a mock of the Hacker News item and user API endpoints
+type itemServer struct {
+	*httptest.Server
+	API HackerNewsAPI
+
+	mu      sync.Mutex
+	items   map[StoryID]Story
+	users   map[string]User
+	failing map[StoryID]bool
+	lookups map[StoryID]int
+}
+
+func newItemServer(t *testing.T, items map[StoryID]Story) *itemServer {
+	srv := &itemServer{
+		items:   items,
+		users:   make(map[string]User),
+		failing: make(map[StoryID]bool),
+		lookups: make(map[StoryID]int),
+	}
+	url_re := regexp.MustCompile(`/(\d+)\.json$`)
+	user_re := regexp.MustCompile(`/user/(.+)\.json$`)
+	srv.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if tokens := user_re.FindStringSubmatch(r.URL.Path); len(tokens) == 2 {
+				srv.mu.Lock()
+				defer srv.mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				if user, found := srv.users[tokens[1]]; found {
+					json.NewEncoder(w).Encode(user)
+				} else {
+					w.Write([]byte("null"))
+				}
+				return
+			}
+			tokens := url_re.FindStringSubmatch(r.URL.Path)
+			if len(tokens) < 2 {
+				t.Fatal("Failed to find item ID in URL: ", r.URL)
+			}
+			id, _ := strconv.Atoi(tokens[1])
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			srv.lookups[StoryID(id)]++
+			if srv.failing[StoryID(id)] {
+				http.Error(w, "upstream error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			// Missing items are served as null
+			item, found := srv.items[StoryID(id)]
+			if !found {
+				w.Write([]byte("null"))
+				return
+			}
+			json.NewEncoder(w).Encode(item)
+		}))
+	srv.API = HackerNewsAPI{
+		Story: srv.URL + "/item/%d.json",
+		User:  srv.URL + "/user/%s.json",
+	}
+	return srv
+}
+
+// Set adds or replaces an item
+func (s *itemServer) Set(item Story) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[item.ID] = item
+}
+
+// SetUser adds or replaces a user
+func (s *itemServer) SetUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.ID] = user
+}
+
+// Fail makes lookups of an item fail
+func (s *itemServer) Fail(id StoryID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing[id] = true
+}
+
+// Lookups returns the number of times an item was requested
+func (s *itemServer) Lookups(id StoryID) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookups[id]
+}
+
+func TestGetComments(t *testing.T) {
+	story := Story{ID: 1, Title: "Story", Timestamp: 1000, Kids: []StoryID{2, 3, 4, 5}}
+	items := map[StoryID]Story{
+		2: {ID: 2, By: "alice", Text: "First", Timestamp: 1060, Kids: []StoryID{6, 7}},
+		3: {ID: 3, Type: "comment", Deleted: true, Timestamp: 1120},
+		5: {ID: 5, By: "carol", Text: "Third", Timestamp: 8200},
+		6: {ID: 6, By: "dave", Text: "Reply", Timestamp: 1090, Kids: []StoryID{8}},
+		7: {ID: 7, By: "erin", Text: "Other reply", Timestamp: 1100},
+		8: {ID: 8, By: "frank", Text: "Too deep", Timestamp: 1200},
+	}
+	srv := newItemServer(t, items)
+	defer srv.Close()
+	srv.Fail(4)
+	api := srv.API
+
+	config := DefaultConfig()
+	config.Comments = 3
+	config.CommentDepth = 2
+	storyCache := cache.New(time.Minute, time.Minute)
+
+	threads, failures := getComments(context.Background(), api, config, []Story{story}, storyCache)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, "4", failures[0].Item)
+
+	// Only the top 3 comments, without the deleted one and the one which
+	// failed to load, and a single level of replies
+	thread := threads[story.ID]
+	assert.Len(t, thread.Replies, 1)
+	assert.Equal(t, "alice", thread.Replies[0].Comment.By)
+	assert.Len(t, thread.Replies[0].Replies, 2)
+	assert.Empty(t, thread.Replies[0].Replies[0].Replies)
+
+	assert.Equal(t, `<ul><li><p><b>alice</b> <time datetime="1970-01-01T00:17:40Z">1 minute after the story</time></p>First`+
+		`<ul><li><p><b>dave</b> <time datetime="1970-01-01T00:18:10Z">1 minute after the story</time></p>Reply</li>`+
+		`<li><p><b>erin</b> <time datetime="1970-01-01T00:18:20Z">1 minute after the story</time></p>Other reply</li></ul>`+
+		`</li></ul>`, renderComments(thread))
+
+	config.Comments = 4
+	threads, _ = getComments(context.Background(), api, config, []Story{story}, storyCache)
+	assert.Len(t, threads[story.ID].Replies, 2)
+	assert.Contains(t, renderComments(threads[story.ID]), "2 hours after the story")
+
+	assert.Equal(t, "", renderComments(&commentThread{Comment: Story{ID: 9}}))
+}
+
+func TestRelativeTime(t *testing.T) {
+	assert.Equal(t, "30 seconds", relativeTime(30*time.Second))
+	assert.Equal(t, "1 minute", relativeTime(90*time.Second))
+	assert.Equal(t, "5 hours", relativeTime(5*time.Hour))
+	assert.Equal(t, "2 days", relativeTime(50*time.Hour))
+}
@@ -0,0 +1,88 @@
+package hackernews
+
+import (
+	"flag"
+	"time"
+)
+
+// Config tunes the lookups and refreshes of the Hacker News feeds
+type Config struct {
+	FetchTimeout    time.Duration `yaml:"fetch_timeout"`
+	CacheTime       time.Duration `yaml:"cache_time"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	NumStoryLookups int           `yaml:"num_story_lookups"`
+	Comments        int           `yaml:"comments"`
+	CommentDepth    int           `yaml:"comment_depth"`
+	UserCacheTime   time.Duration `yaml:"user_cache_time"`
+	UpdateInterval  time.Duration `yaml:"update_interval"`
+	Hiring          bool          `yaml:"hiring"`
+	MaxFailureRatio float64       `yaml:"max_failure_ratio"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		FetchTimeout:    Timeout,
+		CacheTime:       CacheTime,
+		RefreshInterval: RefreshInterval,
+		NumStoryLookups: NumStoryLookups,
+		CommentDepth:    CommentDepth,
+		UserCacheTime:   UserCacheTime,
+		UpdateInterval:  UpdateInterval,
+		MaxFailureRatio: MaxFailureRatio,
+	}
+}
+
+func (c *Config) Flags(fs *flag.FlagSet, prefix string) {
+	fs.DurationVar(&c.FetchTimeout, prefix+"fetch-timeout", c.FetchTimeout,
+		"Timeout of the story lookups of a refresh")
+	fs.DurationVar(&c.CacheTime, prefix+"cache-time", c.CacheTime,
+		"How long the items of the comment and user feeds are cached")
+	fs.DurationVar(&c.RefreshInterval, prefix+"refresh-interval", c.RefreshInterval,
+		"How often story lists are refreshed")
+	fs.IntVar(&c.NumStoryLookups, prefix+"num-story-lookups", c.NumStoryLookups,
+		"Number of concurrent story lookups")
+	fs.IntVar(&c.Comments, prefix+"comments", c.Comments,
+		"Top comments per story and reply level included in entries (0 to disable)")
+	fs.IntVar(&c.CommentDepth, prefix+"comment-depth", c.CommentDepth,
+		"Levels of replies included in entries")
+	fs.DurationVar(&c.UserCacheTime, prefix+"user-cache-time", c.UserCacheTime,
+		"How long users are cached")
+	fs.DurationVar(&c.UpdateInterval, prefix+"update-interval", c.UpdateInterval,
+		"How often changed stories are looked up again (0 to disable)")
+	fs.BoolVar(&c.Hiring, prefix+"hiring", c.Hiring,
+		"Serve the jobs of the latest \"Who is hiring?\" thread on /hiring")
+	fs.Float64Var(&c.MaxFailureRatio, prefix+"max-failure-ratio", c.MaxFailureRatio,
+		"Share of the story lookups of a refresh allowed to fail")
+}
+
+func (c *Config) Validate(prefix string) []string {
+	var problems []string
+	if c.FetchTimeout <= 0 {
+		problems = append(problems, prefix+"fetch-timeout: must be positive")
+	}
+	if c.CacheTime <= 0 {
+		problems = append(problems, prefix+"cache-time: must be positive")
+	}
+	if c.RefreshInterval <= 0 {
+		problems = append(problems, prefix+"refresh-interval: must be positive")
+	}
+	if c.NumStoryLookups < 1 {
+		problems = append(problems, prefix+"num-story-lookups: must be at least 1")
+	}
+	if c.Comments < 0 {
+		problems = append(problems, prefix+"comments: must not be negative")
+	}
+	if c.CommentDepth < 1 {
+		problems = append(problems, prefix+"comment-depth: must be at least 1")
+	}
+	if c.UserCacheTime <= 0 {
+		problems = append(problems, prefix+"user-cache-time: must be positive")
+	}
+	if c.UpdateInterval < 0 {
+		problems = append(problems, prefix+"update-interval: must not be negative")
+	}
+	if c.MaxFailureRatio < 0 || c.MaxFailureRatio > 1 {
+		problems = append(problems, prefix+"max-failure-ratio: must be between 0 and 1")
+	}
+	return problems
+}
@@ -0,0 +1,52 @@
+package hackernews
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("Flags", func(t *testing.T) {
+		config := DefaultConfig()
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		config.Flags(fs, "hackernews.")
+		err := fs.Parse([]string{
+			"-hackernews.refresh-interval", "1m",
+			"-hackernews.num-story-lookups", "10",
+			"-hackernews.comments", "3",
+			"-hackernews.max-failure-ratio", "0.5",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, time.Minute, config.RefreshInterval)
+		assert.Equal(t, 10, config.NumStoryLookups)
+		assert.Equal(t, CacheTime, config.CacheTime)
+		assert.Equal(t, 3, config.Comments)
+		assert.Equal(t, CommentDepth, config.CommentDepth)
+		assert.Equal(t, 0.5, config.MaxFailureRatio)
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		config := DefaultConfig()
+		assert.Empty(t, config.Validate("hackernews."))
+
+		config.RefreshInterval = 0
+		config.NumStoryLookups = 0
+		config.Comments = -1
+		config.CommentDepth = 0
+		config.UserCacheTime = 0
+		config.UpdateInterval = -time.Second
+		config.MaxFailureRatio = 1.5
+		assert.Equal(t, []string{
+			"hackernews.refresh-interval: must be positive",
+			"hackernews.num-story-lookups: must be at least 1",
+			"hackernews.comments: must not be negative",
+			"hackernews.comment-depth: must be at least 1",
+			"hackernews.user-cache-time: must be positive",
+			"hackernews.update-interval: must not be negative",
+			"hackernews.max-failure-ratio: must be between 0 and 1",
+		}, config.Validate("hackernews."))
+	})
+}
@@ -0,0 +1,123 @@
+package hackernews
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+)
+
+// StoryFilter selects stories based on the query parameters of a feed
+// request, e.g. "?points=100&comments=50&q=golang&domain=github.com".
+type StoryFilter struct {
+	MinPoints      int
+	MinComments    int
+	Keywords       []string
+	Domains        []string
+	ExcludeDomains []string
+	By             string
+}
+
+func parseCount(params url.Values, name string) (int, error) {
+	value := params.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid value for %q: %q", name, value)
+	}
+	return n, nil
+}
+
+func parseList(params url.Values, name string) []string {
+	list := make([]string, 0)
+	for _, value := range params[name] {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.ToLower(strings.TrimSpace(v))
+			if v != "" {
+				list = append(list, v)
+			}
+		}
+	}
+	return list
+}
+
+func parseStoryFilter(params url.Values) (StoryFilter, error) {
+	var filter StoryFilter
+	var err error
+
+	if filter.MinPoints, err = parseCount(params, "points"); err != nil {
+		return StoryFilter{}, err
+	}
+	if filter.MinComments, err = parseCount(params, "comments"); err != nil {
+		return StoryFilter{}, err
+	}
+	filter.Keywords = strings.Fields(strings.ToLower(params.Get("q")))
+	filter.Domains = parseList(params, "domain")
+	filter.ExcludeDomains = parseList(params, "exclude_domain")
+	filter.By = strings.TrimSpace(params.Get("by"))
+
+	return filter, nil
+}
+
+// matchDomain reports whether host is domain or one of its subdomains
+func matchDomain(host string, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func (f StoryFilter) IsEmpty() bool {
+	return f.MinPoints == 0 && f.MinComments == 0 && f.By == "" &&
+		len(f.Keywords) == 0 && len(f.Domains) == 0 &&
+		len(f.ExcludeDomains) == 0
+}
+
+func (f StoryFilter) Match(story Story) bool {
+	if story.Score < f.MinPoints || story.Descendants < f.MinComments {
+		return false
+	}
+	if f.By != "" && !strings.EqualFold(story.By, f.By) {
+		return false
+	}
+
+	text := strings.ToLower(story.Title + " " + story.Text)
+	for _, keyword := range f.Keywords {
+		if !strings.Contains(text, keyword) {
+			return false
+		}
+	}
+
+	host := ""
+	if u, err := url.Parse(story.URL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	for _, domain := range f.ExcludeDomains {
+		if matchDomain(host, domain) {
+			return false
+		}
+	}
+	if len(f.Domains) > 0 {
+		for _, domain := range f.Domains {
+			if matchDomain(host, domain) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// Filter narrows down the feed with the StoryFilter query parameters
+func (s *Source) Filter(params url.Values) (feedkit.ItemFilter, error) {
+	filter, err := parseStoryFilter(params)
+	if err != nil || filter.IsEmpty() {
+		return nil, err
+	}
+	return func(item feedkit.Item) bool {
+		story, ok := item.Data.(Story)
+		return ok && filter.Match(story)
+	}, nil
+}
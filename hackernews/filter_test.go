@@ -0,0 +1,91 @@
+package hackernews
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceFilter(t *testing.T) {
+	stories := []Story{
+		{
+			ID:          1,
+			By:          "alice",
+			Score:       250,
+			Descendants: 120,
+			Title:       "Notes on golang generics",
+			URL:         "https://github.com/alice/golang-notes",
+		},
+		{
+			ID:          2,
+			By:          "bob",
+			Score:       80,
+			Descendants: 10,
+			Title:       "Why we left Go",
+			URL:         "https://medium.com/@bob/why-we-left",
+		},
+		{
+			ID:          3,
+			By:          "carol",
+			Score:       400,
+			Descendants: 600,
+			Title:       "Ask HN: Favourite Go libraries?",
+			Text:        "Looking for golang recommendations",
+		},
+	}
+
+	tests := []struct {
+		Name  string
+		Query string
+		Want  []StoryID
+	}{
+		{"NoFilter", "", []StoryID{1, 2, 3}},
+		{"Points", "points=100", []StoryID{1, 3}},
+		{"Comments", "comments=200", []StoryID{3}},
+		{"Keyword", "q=golang", []StoryID{1, 3}},
+		{"KeywordCase", "q=GO", []StoryID{1, 2, 3}},
+		{"MultipleKeywords", "q=ask+libraries", []StoryID{3}},
+		{"Domain", "domain=github.com", []StoryID{1}},
+		{"DomainList", "domain=github.com,medium.com", []StoryID{1, 2}},
+		{"ExcludeDomain", "exclude_domain=medium.com", []StoryID{1, 3}},
+		{"By", "by=bob", []StoryID{2}},
+		{"Combined", "points=100&q=go&exclude_domain=github.com", []StoryID{3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			params, err := url.ParseQuery(test.Query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, err := (&Source{}).Filter(params)
+			assert.Nil(t, err)
+
+			ids := make([]StoryID, 0)
+			for _, story := range stories {
+				if filter == nil || filter(storyItem(story)) {
+					ids = append(ids, story.ID)
+				}
+			}
+			assert.Equal(t, test.Want, ids)
+		})
+	}
+}
+
+func TestBadFilter(t *testing.T) {
+	feed := feedkit.NewFeed(BestStories.Path, &Source{List: BestStories, Config: DefaultConfig()}, feedkit.NewFeedConfig())
+
+	for _, query := range []string{"points=many", "comments=-1"} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?"+query, nil)
+			rr := httptest.NewRecorder()
+
+			feed.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
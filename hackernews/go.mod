@@ -3,8 +3,9 @@ module duh-uh.com/app/rss-feeds/hackernews
 go 1.15
 
 require (
-	github.com/gorilla/feeds v1.1.1
-	github.com/kr/pretty v0.2.1 // indirect
+	duh-uh.com/app/rss-feeds/feedkit v0.0.0
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/stretchr/testify v1.7.0
 )
+
+replace duh-uh.com/app/rss-feeds/feedkit => ../feedkit
@@ -1,129 +1,268 @@
-package main
+// Package hackernews builds feeds from the Hacker News story lists, using
+// the Hacker News Firebase API, and searches of the Algolia search API.
+package hackernews
 
 import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"regexp"
 	"sort"
 	"strconv"
 	"sync"
 	"time"
 
-	"github.com/gorilla/feeds"
+	"duh-uh.com/app/rss-feeds/feedkit"
 	"github.com/patrickmn/go-cache"
 )
 
 const (
-	FeedURL         = "https://news.ycombinator.com/best"
-	FeedTitle       = "Hacker News"
-	FeedDescription = "Hacker News Top Stories"
-	FeedAuthor      = "Venky"
-	FeedAuthorEmail = "venkytv@gmail.com"
-	StoryListURL    = "https://hacker-news.firebaseio.com/v0/beststories.json"
+	StoryListURL    = "https://hacker-news.firebaseio.com/v0/%s.json"
 	StoryURL        = "https://hacker-news.firebaseio.com/v0/item/%d.json"
+	UserURL         = "https://hacker-news.firebaseio.com/v0/user/%s.json"
+	UpdatesURL      = "https://hacker-news.firebaseio.com/v0/updates.json"
+	SearchURL       = "https://hn.algolia.com/api/v1/search_by_date?%s"
 	HNSourceURL     = "https://news.ycombinator.com/item?id=%d"
-	TwitterRE       = `^https://(?:twitter|x)\.com/(.*)`
-	ThreaderURL     = "https://nitter.net/%s"
-	Timeout         = 10 * time.Second
-	CacheTime       = 24 * time.Hour
-	RefreshInterval = 10 * time.Minute
-	NumStoryLookups = 50
+	Timeout         = 10 * time.Second   // Default FetchTimeout
+	CacheTime       = 24 * time.Hour     // Default CacheTime
+	RefreshInterval = 10 * time.Minute   // Default RefreshInterval
+	NumStoryLookups = 50                 // Default NumStoryLookups
+	CommentDepth    = 2                  // Default CommentDepth
+	UserCacheTime   = 10 * time.Minute   // Default UserCacheTime
+	UpdateInterval  = time.Minute        // Default UpdateInterval
+	UserItems       = 30                 // Recent items of a user looked up
+	MaxFailureRatio = 0.2                // Default MaxFailureRatio
+	HistorySize     = 200                // Max stories in a feed
+	HistoryAge      = 48 * time.Hour     // How long stories stay in a feed
+	StoryRetention  = 7 * 24 * time.Hour // How long stories are stored
+
+	// Names of the source and its story cache in metrics
+	SourceName     = "hackernews"
+	StoryCacheName = "hackernews_stories"
+	ItemCacheName  = "hackernews_items"
+	UserCacheName  = "hackernews_users"
 )
 
 type HackerNewsAPI struct {
 	StoryList string
 	Story     string
+	User      string
+	Updates   string
+	Search    string // Algolia search API
 }
 
+func DefaultAPI() HackerNewsAPI {
+	return HackerNewsAPI{
+		StoryList: StoryListURL,
+		Story:     StoryURL,
+		User:      UserURL,
+		Updates:   UpdatesURL,
+		Search:    SearchURL,
+	}
+}
+
+// StoryList describes one of the Firebase story lists and the feed
+// generated from it.
+type StoryList struct {
+	Name        string // Firebase list name, e.g. "beststories"
+	Path        string // Path the feed is served on
+	Title       string
+	Description string
+	URL         string
+}
+
+var (
+	TopStories = StoryList{
+		Name:        "topstories",
+		Path:        "/top",
+		Title:       "Hacker News: Front Page",
+		Description: "Hacker News Front Page Stories",
+		URL:         "https://news.ycombinator.com/news",
+	}
+	NewStories = StoryList{
+		Name:        "newstories",
+		Path:        "/new",
+		Title:       "Hacker News: New",
+		Description: "Hacker News Newest Stories",
+		URL:         "https://news.ycombinator.com/newest",
+	}
+	BestStories = StoryList{
+		Name:        "beststories",
+		Path:        "/best",
+		Title:       "Hacker News",
+		Description: "Hacker News Top Stories",
+		URL:         "https://news.ycombinator.com/best",
+	}
+	AskStories = StoryList{
+		Name:        "askstories",
+		Path:        "/ask",
+		Title:       "Hacker News: Ask HN",
+		Description: "Hacker News Ask HN Stories",
+		URL:         "https://news.ycombinator.com/ask",
+	}
+	ShowStories = StoryList{
+		Name:        "showstories",
+		Path:        "/show",
+		Title:       "Hacker News: Show HN",
+		Description: "Hacker News Show HN Stories",
+		URL:         "https://news.ycombinator.com/show",
+	}
+	JobStories = StoryList{
+		Name:        "jobstories",
+		Path:        "/jobs",
+		Title:       "Hacker News: Jobs",
+		Description: "Hacker News Job Postings",
+		URL:         "https://news.ycombinator.com/jobs",
+	}
+
+	StoryLists = []StoryList{
+		TopStories,
+		NewStories,
+		BestStories,
+		AskStories,
+		ShowStories,
+		JobStories,
+	}
+
+	// Story list served on "/"
+	DefaultStoryList = BestStories
+)
+
 type StoryID int
 
+// Types of Hacker News items
+const (
+	TypeStory   = "story"
+	TypeJob     = "job"
+	TypePoll    = "poll"
+	TypePollOpt = "pollopt"
+	TypeComment = "comment"
+)
+
+// Story is a Hacker News item. Besides stories, it holds the jobs, polls,
+// poll options and comments the API serves from the same endpoint.
 type Story struct {
-	ID        StoryID
-	By        string `json:"by"`
-	Score     int    `json:"score"`
-	Timestamp int64  `json:"time"`
-	Title     string `json:"title"`
-	URL       string `json:"url"`
-	Text      string `json:"text"`
+	ID          StoryID
+	Type        string    `json:"type,omitempty"`
+	By          string    `json:"by"`
+	Score       int       `json:"score"`
+	Descendants int       `json:"descendants"` // Stories and polls
+	Timestamp   int64     `json:"time"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Text        string    `json:"text"`
+	Kids        []StoryID `json:"kids,omitempty"`
+	Parent      StoryID   `json:"parent,omitempty"` // Comments
+	Poll        StoryID   `json:"poll,omitempty"`   // Poll options
+	Parts       []StoryID `json:"parts,omitempty"`  // Polls
+	Dead        bool      `json:"dead,omitempty"`
+	Deleted     bool      `json:"deleted,omitempty"`
+
+	FirstSeen time.Time `json:"first_seen,omitempty"`
 }
 
-func (s Story) Time() time.Time {
-	return time.Unix(s.Timestamp, 0)
+// Removed reports whether the item was killed or deleted
+func (s Story) Removed() bool {
+	return s.Dead || s.Deleted
 }
 
-type FeedConfig struct {
-	Cache             *cache.Cache
-	CacheTimeOverride time.Time // Override for testing
+// Submission reports whether the item is a story, job or poll
+func (s Story) Submission() bool {
+	return s.Type == TypeStory || s.Type == TypeJob || s.Type == TypePoll
 }
 
-func (f FeedConfig) CacheTime() time.Time {
-	feedTime := f.CacheTimeOverride
-	if feedTime.IsZero() {
-		feedTime = time.Now()
-	}
-	return feedTime
+func (s Story) Time() time.Time {
+	return time.Unix(s.Timestamp, 0)
 }
 
-func getStoryFromCache(api HackerNewsAPI, id StoryID, storyCache *cache.Cache) (Story, error) {
+func getStoryFromCache(ctx context.Context, api HackerNewsAPI, id StoryID, storyCache *cache.Cache, timeout time.Duration) (Story, error) {
 	idStr := strconv.Itoa(int(id))
 	story, found := storyCache.Get(idStr)
+	feedkit.ObserveCacheLookup(StoryCacheName, found)
 	if !found {
 		log.Print("Fetching story ", id)
-		var err error
-		story, err = getStory(api, id)
+		start := time.Now()
+		s, err := getStory(ctx, api, id, timeout)
+		feedkit.ObserveUpstream(SourceName, "getStory", start, err)
 		if err != nil {
 			return Story{}, err
 		}
-		storyCache.Set(idStr, story, cache.NoExpiration)
+		s.FirstSeen = time.Now()
+		story = s
+		storyCache.SetDefault(idStr, story)
 	}
 	return story.(Story), nil
 }
 
-func getStory(api HackerNewsAPI, id StoryID) (Story, error) {
+// getStory looks up an item. Items which do not exist fail with an error
+// wrapping feedkit.ErrNotFound.
+func getStory(ctx context.Context, api HackerNewsAPI, id StoryID, timeout time.Duration) (Story, error) {
 	var story Story
 
+	url := fmt.Sprintf(api.Story, id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Story{}, err
+	}
 	client := http.Client{
-		Timeout: time.Duration(Timeout),
+		Timeout: timeout,
 	}
-	url := fmt.Sprintf(api.Story, id)
-	resp, err := client.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
 		return Story{}, err
 	}
 
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Story{}, fmt.Errorf("%s: %s", url, resp.Status)
+	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return Story{}, err
 	}
 
-	json.Unmarshal(body, &story)
+	// Missing items are served as null
+	var item *Story
+	if err := json.Unmarshal(body, &item); err != nil {
+		return Story{}, fmt.Errorf("%s: %v", url, err)
+	}
+	if item == nil {
+		return Story{}, fmt.Errorf("item %d: %w", id, feedkit.ErrNotFound)
+	}
+	story = *item
 	story.ID = id
 	return story, nil
 }
 
-func getTopStoryIDs(api HackerNewsAPI) ([]StoryID, error) {
+func getTopStoryIDs(ctx context.Context, api HackerNewsAPI, list StoryList, timeout time.Duration) ([]StoryID, error) {
+	url := fmt.Sprintf(api.StoryList, list.Name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return []StoryID{}, err
+	}
 	client := http.Client{
-		Timeout: time.Duration(Timeout),
+		Timeout: timeout,
 	}
-	resp, err := client.Get(api.StoryList)
+	resp, err := client.Do(req)
 	if err != nil {
 		return []StoryID{}, err
 	}
 
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return []StoryID{}, fmt.Errorf("%s: %s", url, resp.Status)
+	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return []StoryID{}, err
 	}
 
 	topStories := make([]StoryID, 0)
-	json.Unmarshal(body, &topStories)
+	if err := json.Unmarshal(body, &topStories); err != nil {
+		return []StoryID{}, fmt.Errorf("%s: %v", url, err)
+	}
 
 	sort.Slice(topStories, func(i, j int) bool {
 		return topStories[i] < topStories[j]
@@ -131,20 +270,28 @@ func getTopStoryIDs(api HackerNewsAPI) ([]StoryID, error) {
 	return topStories, nil
 }
 
-func getStories(api HackerNewsAPI, ids []StoryID, storyCache *cache.Cache) ([]Story, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+// lookupItems looks up the given items through the story cache, running
+// config.NumStoryLookups lookups at a time. Items which could not be
+// fetched are returned as failures.
+func lookupItems(ctx context.Context, api HackerNewsAPI, config Config, ids []StoryID, storyCache *cache.Cache) ([]Story, []feedkit.ItemError) {
+	ctx, cancel := context.WithTimeout(ctx, config.FetchTimeout)
 	defer cancel()
 
 	// Pump the list of story IDs into a channel
 	id_chan := make(chan StoryID)
 	go func() {
+		defer close(id_chan)
 		for _, id := range ids {
-			id_chan <- id
+			select {
+			case id_chan <- id:
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(id_chan)
 	}()
 
 	type StoryLookup struct {
+		ID    StoryID
 		Story Story
 		Error error
 	}
@@ -153,19 +300,19 @@ func getStories(api HackerNewsAPI, ids []StoryID, storyCache *cache.Cache) ([]St
 
 	// Start a fixed number of consumers
 	var wg sync.WaitGroup
-	wg.Add(NumStoryLookups)
-	for i := 0; i < NumStoryLookups; i++ {
+	wg.Add(config.NumStoryLookups)
+	for i := 0; i < config.NumStoryLookups; i++ {
 		go func() {
+			defer wg.Done()
 			for id := range id_chan {
-				s, err := getStoryFromCache(api, id, storyCache)
+				s, err := getStoryFromCache(ctx, api, id, storyCache, config.FetchTimeout)
 
 				select {
-				case story_chan <- StoryLookup{s, err}:
+				case story_chan <- StoryLookup{id, s, err}:
 				case <-ctx.Done():
 					return
 				}
 			}
-			wg.Done()
 		}()
 	}
 	go func() {
@@ -174,134 +321,233 @@ func getStories(api HackerNewsAPI, ids []StoryID, storyCache *cache.Cache) ([]St
 	}()
 
 	stories := make([]Story, 0)
+	failures := make([]feedkit.ItemError, 0)
+	found := make(map[StoryID]bool)
 	for s := range story_chan {
+		found[s.ID] = true
 		if s.Error != nil {
-			return nil, s.Error
+			log.Printf("Failed to fetch story %d: %v", s.ID, s.Error)
+			failures = append(failures, feedkit.ItemError{
+				Item:  strconv.Itoa(int(s.ID)),
+				Error: s.Error.Error(),
+			})
+			continue
 		}
 		stories = append(stories, s.Story)
 	}
 
+	// Lookups abandoned when the context expired
+	for _, id := range ids {
+		if !found[id] {
+			failures = append(failures, feedkit.ItemError{
+				Item:  strconv.Itoa(int(id)),
+				Error: ctx.Err().Error(),
+			})
+		}
+	}
+
+	return stories, failures
+}
+
+// getStories looks up the given stories, skipping the ones which could not
+// be fetched. It only fails if the share of failed lookups is above
+// config.MaxFailureRatio.
+func getStories(ctx context.Context, api HackerNewsAPI, config Config, ids []StoryID, storyCache *cache.Cache) ([]Story, []feedkit.ItemError, error) {
+	stories, failures := lookupItems(ctx, api, config, ids, storyCache)
+	if feedkit.TooManyFailures(len(failures), len(ids), config.MaxFailureRatio) {
+		return nil, failures, fmt.Errorf("%d of %d story lookups failed",
+			len(failures), len(ids))
+	}
+
+	// Stories which were killed or deleted since they were listed
+	live := stories[:0]
+	for _, story := range stories {
+		if !story.Removed() {
+			live = append(live, story)
+		}
+	}
+	stories = live
+
 	sort.Slice(stories, func(i, j int) bool {
 		return stories[i].Timestamp > stories[j].Timestamp
 	})
 
-	return stories, nil
+	return stories, failures, nil
 }
 
-func getTopStories(api HackerNewsAPI, storyCache *cache.Cache) ([]Story, error) {
-	ids, err := getTopStoryIDs(api)
+func getTopStories(ctx context.Context, api HackerNewsAPI, config Config, list StoryList, storyCache *cache.Cache, store feedkit.Store) ([]Story, []feedkit.ItemError, error) {
+	start := time.Now()
+	ids, err := getTopStoryIDs(ctx, api, list, config.FetchTimeout)
+	feedkit.ObserveUpstream(SourceName, "getTopStoryIDs", start, err)
 	if err != nil {
 		log.Print(err)
-		return nil, err
+		return nil, nil, err
+	}
+
+	if store != nil {
+		ids, err = storyHistory(store, list, ids, time.Now())
+		if err != nil {
+			log.Print("Failed to load story history: ", err)
+		}
 	}
 
-	stories, err := getStories(api, ids, storyCache)
+	stories, failures, err := getStories(ctx, api, config, ids, storyCache)
+	feedkit.SetCacheSize(StoryCacheName, storyCache.ItemCount())
 	if err != nil {
 		log.Print(err)
-		return nil, err
+		return nil, failures, err
 	}
 
-	return stories, nil
+	if store != nil {
+		if err := saveStories(store, stories); err != nil {
+			log.Print("Failed to save stories: ", err)
+		}
+	}
+
+	return stories, failures, nil
 }
 
-func unrollTwitterThread(stories []Story) []Story {
-	re, err := regexp.Compile(TwitterRE)
-	if err != nil {
-		log.Println(err)
-		return stories
+// Source is the feed of a Hacker News story list
+type Source struct {
+	API    HackerNewsAPI
+	List   StoryList
+	Config Config
+	Cache  *cache.Cache // Story cache, shared by all story lists
+	Store  feedkit.Store
+}
+
+func (s *Source) Metadata() feedkit.Metadata {
+	return feedkit.Metadata{
+		Title:       s.List.Title,
+		Link:        s.List.URL,
+		Description: s.List.Description,
 	}
-	for idx, _ := range stories {
-		m := re.FindStringSubmatch(stories[idx].URL)
-		if len(m) > 0 {
-			stories[idx].URL = fmt.Sprintf(ThreaderURL, m[1])
-		}
+}
+
+func storyItem(story Story) feedkit.Item {
+	source := fmt.Sprintf(HNSourceURL, story.ID)
+	link := story.URL
+	if link == "" {
+		link = source
+	}
+	item := feedkit.Item{
+		ID:          source,
+		Title:       story.Title,
+		Link:        link,
+		Source:      source,
+		Description: story.Text,
+		Created:     story.Time(),
+		Data:        story,
 	}
-	return stories
+	// Jobs have no score or comments, so readers can tell them apart
+	if story.Type == TypeJob {
+		item.Author = JobAuthor
+		item.Categories = []string{JobCategory}
+	}
+	return item
 }
 
-func storyHandler(api HackerNewsAPI, feedConfig FeedConfig) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		stories, err := getTopStories(api, feedConfig.Cache)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+func (s *Source) Fetch(ctx context.Context) ([]feedkit.Item, []feedkit.ItemError, error) {
+	stories, failures, err := getTopStories(ctx, s.API, s.Config, s.List, s.Cache, s.Store)
+	if err != nil {
+		return nil, failures, err
+	}
 
-		stories = unrollTwitterThread(stories)
+	var threads map[StoryID]*commentThread
+	if s.Config.Comments > 0 {
+		var failed []feedkit.ItemError
+		threads, failed = getComments(ctx, s.API, s.Config, stories, s.Cache)
+		failures = append(failures, failed...)
+	}
+	options, failed := getPollOptions(ctx, s.API, s.Config, stories, s.Cache)
+	failures = append(failures, failed...)
+
+	items := make([]feedkit.Item, 0, len(stories))
+	for _, story := range stories {
+		item := storyItem(story)
+		item.Content = renderPoll(options[story.ID]) + renderComments(threads[story.ID])
+		items = append(items, item)
+	}
+	return items, failures, nil
+}
 
-		feed := &feeds.Feed{
-			Title:       FeedTitle,
-			Link:        &feeds.Link{Href: FeedURL},
-			Description: FeedDescription,
-			Author:      &feeds.Author{Name: FeedAuthor, Email: FeedAuthorEmail},
-			Created:     feedConfig.CacheTime(),
-		}
-		for _, story := range stories {
-			link := story.URL
-			source := fmt.Sprintf(HNSourceURL, story.ID)
-			if link == "" {
-				link = source
-			}
-			feed.Add(&feeds.Item{
-				Title:       story.Title,
-				Link:        &feeds.Link{Href: link},
-				Source:      &feeds.Link{Href: source},
-				Description: story.Text,
-				Id:          source,
-				Created:     story.Time(),
-			})
+// NewStoryCache returns a story cache filled with the stories in the store.
+// Stories are kept until they are dropped from the store, see loadStories.
+func NewStoryCache(config Config, store feedkit.Store) *cache.Cache {
+	storyCache := cache.New(cache.NoExpiration, 0)
+	if store != nil {
+		if err := loadStories(store, storyCache); err != nil {
+			log.Print("Failed to load stories: ", err)
 		}
+	}
+	feedkit.SetCacheSize(StoryCacheName, storyCache.ItemCount())
+	return storyCache
+}
 
-		atom, err := feed.ToAtom()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+// NewItemCache returns the cache of the items of the feeds built on
+// request, which may be any item. Its items expire after CacheTime and are
+// not stored, unlike the stories in the story cache.
+func NewItemCache(config Config) *cache.Cache {
+	return cache.New(config.CacheTime, config.CacheTime)
+}
+
+// exactPath serves handler on path only. Other paths below it, e.g. a
+// mistyped story list, are not found.
+func exactPath(path string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != path {
+			http.NotFound(w, req)
 			return
 		}
-
-		io.WriteString(w, atom)
+		handler.ServeHTTP(w, req)
 	})
 }
 
-func main() {
-	api := HackerNewsAPI{
-		StoryList: StoryListURL,
-		Story:     StoryURL,
+// Mount serves the feeds of all story lists under prefix, with the
+// DefaultStoryList feed served on the prefix itself
+func Mount(server *feedkit.Server, prefix string, api HackerNewsAPI, config Config, store feedkit.Store) {
+	storyCache := NewStoryCache(config, store)
+	if store != nil {
+		server.OnShutdown(func() error {
+			return flushStories(store, storyCache)
+		})
 	}
-
-	storyCache := cache.New(CacheTime, 2*CacheTime)
-	feedConfig := FeedConfig{
-		Cache: storyCache,
+	for _, list := range StoryLists {
+		source := &Source{
+			API:    api,
+			List:   list,
+			Config: config,
+			Cache:  storyCache,
+			Store:  store,
+		}
+		feed := server.Mount(prefix+list.Path, source, config.RefreshInterval)
+		if list == DefaultStoryList {
+			server.Handle(prefix+"/", exactPath(prefix+"/", feed))
+		}
 	}
-
-	// Cache stories at startup
-	getTopStories(api, storyCache)
-
-	ticker := time.NewTicker(RefreshInterval)
-	defer ticker.Stop()
-
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				// Refresh cache
-				getTopStories(api, storyCache)
-			}
+	itemCache := NewItemCache(config)
+	server.MountSet(prefix+"/item/", newCommentsSource(api, config, itemCache),
+		config.RefreshInterval)
+	userCache := cache.New(config.UserCacheTime, config.UserCacheTime)
+	server.MountSet(prefix+"/user/", newUserSource(api, config, itemCache, userCache),
+		config.RefreshInterval)
+	search := server.MountSet(prefix+"/search", newSearchSource(api, config),
+		config.RefreshInterval)
+	search.Key = searchKey
+	if config.Hiring {
+		hiring := &HiringSource{
+			API:    api,
+			Config: config,
+			Cache:  storyCache,
+			Users:  userCache,
 		}
-	}()
-
-	log.Print("Starting server")
-	srv := http.Server{
-		Addr:         ":8080",
-		ReadTimeout:  Timeout / 2.0,
-		WriteTimeout: Timeout,
-		Handler: http.TimeoutHandler(storyHandler(api, feedConfig),
-			Timeout, "Timeout!\n"),
+		server.Mount(prefix+"/hiring", hiring, config.RefreshInterval)
 	}
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed: %v\n", err)
+	if config.UpdateInterval > 0 {
+		server.Every("hackernews updates", config.UpdateInterval, func(ctx context.Context) error {
+			_, err := updateStories(ctx, api, config, storyCache, itemCache, userCache)
+			return err
+		})
 	}
 }
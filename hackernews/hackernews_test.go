@@ -1,6 +1,8 @@
-package main
+package hackernews
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -11,14 +13,37 @@ import (
 	"testing"
 	"time"
 
+	"duh-uh.com/app/rss-feeds/feedkit"
 	"github.com/patrickmn/go-cache"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestExactPath(t *testing.T) {
+	handler := exactPath("/hackernews/", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("feed"))
+		}))
+
+	for _, test := range []struct {
+		Path string
+		Code int
+	}{
+		{"/hackernews/", http.StatusOK},
+		{"/hackernews/tpo", http.StatusNotFound},
+		{"/hackernews/best/", http.StatusNotFound},
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", test.Path, nil))
+		assert.Equal(t, test.Code, rr.Code, test.Path)
+	}
+}
+
 func TestGetTopStories(t *testing.T) {
 	// Mock server for the Hacker News story list API endpoint
+	var storyListPath string
 	storyListSrv := httptest.NewServer(http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			storyListPath = r.URL.Path
 			bytes, err := ioutil.ReadFile("testdata/story-list.json")
 			if err != nil {
 				t.Fatal(err)
@@ -46,15 +71,20 @@ func TestGetTopStories(t *testing.T) {
 	defer storySrv.Close()
 
 	api := HackerNewsAPI{
-		StoryList: storyListSrv.URL,
+		StoryList: storyListSrv.URL + "/%s.json",
 		Story:     storySrv.URL + "/%d.json",
 	}
 
 	cacheTime, err := time.Parse(time.RFC3339, "2021-05-25T10:29:48+02:00")
-	feedConfig := FeedConfig{
-		Cache:             cache.New(0, 0),
-		CacheTimeOverride: cacheTime,
+	feedConfig := feedkit.NewFeedConfig()
+	feedConfig.CacheTimeOverride = cacheTime
+	source := &Source{
+		API:    api,
+		List:   BestStories,
+		Config: DefaultConfig(),
+		Cache:  cache.New(0, 0),
 	}
+	feed := feedkit.NewFeed(BestStories.Path, source, feedConfig)
 
 	bytes, err := ioutil.ReadFile("testdata/feed.xml")
 	if err != nil {
@@ -66,8 +96,10 @@ func TestGetTopStories(t *testing.T) {
 		req := httptest.NewRequest("GET", "/", nil)
 		rr := httptest.NewRecorder()
 
-		storyHandler(api, feedConfig).ServeHTTP(rr, req)
+		feed.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "/beststories.json", storyListPath)
+		assert.Equal(t, feedkit.FormatAtom.ContentType(), rr.Header().Get("Content-Type"))
 
 		resp := rr.Result()
 		defer resp.Body.Close()
@@ -88,7 +120,7 @@ func TestGetTopStories(t *testing.T) {
 		req := httptest.NewRequest("GET", "/", nil)
 		rr := httptest.NewRecorder()
 
-		storyHandler(api, feedConfig).ServeHTTP(rr, req)
+		feed.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusOK, rr.Code)
 
 		resp := rr.Result()
@@ -99,22 +131,149 @@ func TestGetTopStories(t *testing.T) {
 		}
 		assert.Equal(t, wantFeed, string(body))
 	})
+
+	t.Run("FetchFilteredFeed", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/?points=250", nil)
+		rr := httptest.NewRecorder()
+
+		feed.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 3, strings.Count(rr.Body.String(), "<entry>"))
+		assert.NotContains(t, rr.Body.String(), "Writing Pythonic Rust")
+	})
+
+	t.Run("RefreshUnchangedFeed", func(t *testing.T) {
+		before, err := feed.Snapshot(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, err := feed.Refresh(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, before.Version, after.Version)
+		assert.Equal(t, before.Bodies[feedkit.FormatAtom].ETag, after.Bodies[feedkit.FormatAtom].ETag)
+	})
+}
+
+func TestGetStoriesPartialFailure(t *testing.T) {
+	// Mock server for the story details endpoint, missing some stories
+	var missing map[string]bool
+	url_re := regexp.MustCompile(`/(\d+)\.json$`)
+	storySrv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			tokens := url_re.FindStringSubmatch(r.URL.Path)
+			if len(tokens) < 1 || missing[tokens[1]] {
+				http.NotFound(w, r)
+				return
+			}
+			bytes, err := ioutil.ReadFile("testdata/" + tokens[1] + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(bytes)
+		}))
+	defer storySrv.Close()
+
+	api := HackerNewsAPI{
+		Story: storySrv.URL + "/%d.json",
+	}
+	ids := []StoryID{27219759, 27262193, 27266485, 27266551, 27267066}
+
+	t.Run("SingleFailure", func(t *testing.T) {
+		missing = map[string]bool{"27262193": true}
+		stories, failures, err := getStories(context.Background(), api, DefaultConfig(), ids, cache.New(0, 0))
+		assert.Nil(t, err)
+		assert.Len(t, stories, 4)
+		assert.Len(t, failures, 1)
+		assert.Equal(t, "27262193", failures[0].Item)
+	})
+
+	t.Run("TooManyFailures", func(t *testing.T) {
+		missing = map[string]bool{"27262193": true, "27266485": true}
+		stories, failures, err := getStories(context.Background(), api, DefaultConfig(), ids, cache.New(0, 0))
+		assert.NotNil(t, err)
+		assert.Nil(t, stories)
+		assert.Len(t, failures, 2)
+	})
+
+	t.Run("MaxFailureRatio", func(t *testing.T) {
+		missing = map[string]bool{"27262193": true, "27266485": true}
+		config := DefaultConfig()
+		config.MaxFailureRatio = 0.5
+		stories, failures, err := getStories(context.Background(), api, config, ids, cache.New(0, 0))
+		assert.Nil(t, err)
+		assert.Len(t, stories, 3)
+		assert.Len(t, failures, 2)
+	})
 }
 
-func TestUnrollTwitterThread(t *testing.T) {
-	URL := "https://twitter.com/BrantlyMillegan/status/1402388133086367751"
-	unrolledURL := "https://nitter.net/BrantlyMillegan/status/1402388133086367751"
-	stories := []Story{
-		{
-			ID:        123,
-			By:        "Someone",
-			Score:     100,
-			Timestamp: 1624096448,
-			URL:       URL,
-		},
+func TestGetStory(t *testing.T) {
+	payloads := map[string]string{
+		"1": `{"by":"pg","descendants":2,"id":1,"kids":[3],"parts":[2],"score":10,"time":1000,"title":"Poll","type":"poll"}`,
+		"2": `{"by":"pg","id":2,"poll":1,"score":7,"text":"Yes","time":1000,"type":"pollopt"}`,
+		"3": `{"by":"pg","id":3,"parent":1,"text":"Comment","time":1100,"type":"comment"}`,
+		"4": `{"deleted":true,"id":4,"parent":1,"time":1200,"type":"comment"}`,
+		"5": `null`,
+		"6": `{"id":6,"title":`,
 	}
-	stories = unrollTwitterThread(stories)
-	assert.Equal(t, unrolledURL, stories[0].URL)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(payloads[strings.Trim(r.URL.Path, "/")]))
+		}))
+	defer srv.Close()
+	api := HackerNewsAPI{Story: srv.URL + "/%d"}
+
+	poll, err := getStory(context.Background(), api, 1, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, TypePoll, poll.Type)
+	assert.Equal(t, []StoryID{2}, poll.Parts)
+	assert.Equal(t, 2, poll.Descendants)
+	assert.True(t, poll.Submission())
+
+	option, err := getStory(context.Background(), api, 2, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, StoryID(1), option.Poll)
+	assert.False(t, option.Submission())
+
+	comment, err := getStory(context.Background(), api, 3, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, StoryID(1), comment.Parent)
+	assert.False(t, comment.Removed())
+
+	deleted, err := getStory(context.Background(), api, 4, time.Second)
+	assert.Nil(t, err)
+	assert.True(t, deleted.Removed())
+
+	_, err = getStory(context.Background(), api, 5, time.Second)
+	assert.True(t, errors.Is(err, feedkit.ErrNotFound))
+
+	_, err = getStory(context.Background(), api, 6, time.Second)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, feedkit.ErrNotFound))
+}
+
+func TestGetStoryCancelled(t *testing.T) {
+	// Mock server which only answers once the test is over
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+		}))
+	defer srv.Close()
+	defer close(done)
+	api := HackerNewsAPI{Story: srv.URL + "/%d"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := getStory(ctx, api, 1, time.Minute)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
 }
 
 func TestMain(m *testing.M) {
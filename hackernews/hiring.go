@@ -0,0 +1,273 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	HiringUser     = "whoishiring"            // Poster of the monthly threads
+	HiringTitle    = "Ask HN: Who is hiring?" // Title prefix of the hiring threads
+	HiringLookups  = 10                       // Recent submissions searched for the thread
+	RemoteCategory = "remote"                 // Category of remote jobs
+)
+
+var (
+	tagRE    = regexp.MustCompile(`<[^>]*>`)
+	salaryRE = regexp.MustCompile(`(?i)[$€£]\s*\d|\d\s*k\b|\bsalary\b|\d{2,3},\d{3}`)
+	// Header fields which are neither a role nor a location
+	termsRE = regexp.MustCompile(`(?i)^\s*(full[- ]?time|part[- ]?time|on-?site|in[- ]office|hybrid|contract|freelance|interns?(hip)?|visa)`)
+)
+
+// Job is a top-level comment of a hiring thread, with the fields of its
+// "Company | Role | Location | REMOTE | Salary" header line
+type Job struct {
+	Comment  Story
+	Header   string
+	Company  string
+	Role     string
+	Location string
+	Remote   bool
+	Salary   string
+	Terms    []string // e.g. "Full-time", "VISA"
+}
+
+// plainText converts the HTML of a comment to text, one paragraph per
+// line
+func plainText(text string) string {
+	text = strings.ReplaceAll(text, "<p>", "\n")
+	return strings.TrimSpace(html.UnescapeString(tagRE.ReplaceAllString(text, "")))
+}
+
+// headerLine returns the first line of a comment as plain text
+func headerLine(text string) string {
+	return strings.TrimSpace(strings.SplitN(plainText(text), "\n", 2)[0])
+}
+
+// parseJob parses the header line of a hiring comment. The company always
+// comes first; the other fields are told apart by their contents, and the
+// first two fields left are taken as the role and the location.
+func parseJob(comment Story) Job {
+	job := Job{Comment: comment, Header: headerLine(comment.Text)}
+	fields := strings.Split(job.Header, "|")
+	if len(fields) < 2 {
+		return job
+	}
+
+	job.Company = strings.TrimSpace(fields[0])
+	for _, field := range fields[1:] {
+		field = strings.TrimSpace(field)
+		lower := strings.ToLower(field)
+		if strings.Contains(lower, "remote") {
+			job.Remote = true
+			if strings.HasPrefix(lower, "remote") {
+				continue
+			}
+		}
+		switch {
+		case field == "":
+		case salaryRE.MatchString(field) && job.Salary == "":
+			job.Salary = field
+		case termsRE.MatchString(field):
+			job.Terms = append(job.Terms, field)
+		case job.Role == "":
+			job.Role = field
+		case job.Location == "":
+			job.Location = field
+		default:
+			job.Terms = append(job.Terms, field)
+		}
+	}
+	return job
+}
+
+func (j Job) Title() string {
+	if j.Company == "" {
+		return j.Header
+	}
+	title := j.Company
+	if j.Role != "" {
+		title += ": " + j.Role
+	}
+	if j.Location != "" {
+		title += " (" + j.Location + ")"
+	}
+	return title
+}
+
+func jobItem(job Job) feedkit.Item {
+	link := fmt.Sprintf(HNSourceURL, job.Comment.ID)
+	item := feedkit.Item{
+		ID:          link,
+		Title:       job.Title(),
+		Link:        link,
+		Source:      link,
+		Description: job.Comment.Text,
+		Created:     job.Comment.Time(),
+		Author:      job.Company,
+		Data:        job,
+	}
+	if job.Remote {
+		item.Categories = []string{RemoteCategory}
+	}
+	return item
+}
+
+// HiringSource is the feed of the jobs posted in the latest "Who is
+// hiring?" thread, served on /hiring
+type HiringSource struct {
+	API    HackerNewsAPI
+	Config Config
+	Cache  *cache.Cache // Story cache, shared with the story lists
+	Users  *cache.Cache // User cache, shared with the user feeds
+
+	mu     sync.Mutex // Guards thread, which Metadata reads during refreshes
+	thread Story
+}
+
+func (s *HiringSource) Metadata() feedkit.Metadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	title := s.thread.Title
+	if title == "" {
+		title = HiringTitle
+	}
+	return feedkit.Metadata{
+		Title:       title,
+		Link:        fmt.Sprintf(HNSourceURL, s.thread.ID),
+		Description: "Jobs posted in the monthly Hacker News hiring thread",
+	}
+}
+
+// findThread returns the latest hiring thread among the recent submissions
+// of HiringUser
+func (s *HiringSource) findThread(ctx context.Context) (StoryID, error) {
+	user, err := getUserFromCache(ctx, s.API, HiringUser, s.Users, s.Config.FetchTimeout)
+	if err != nil {
+		return 0, err
+	}
+	ids := user.Submitted
+	if len(ids) > HiringLookups {
+		ids = ids[:HiringLookups]
+	}
+
+	stories, _ := lookupItems(ctx, s.API, s.Config, ids, s.Cache)
+	sort.Slice(stories, func(i, j int) bool {
+		return stories[i].Timestamp > stories[j].Timestamp
+	})
+	for _, story := range stories {
+		if strings.HasPrefix(story.Title, HiringTitle) {
+			return story.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("no hiring thread in the last %d submissions of %s",
+		len(ids), HiringUser)
+}
+
+// Fetch returns the jobs posted in the latest hiring thread, newest first.
+// The thread is looked up on every refresh to find new comments, which
+// are looked up through the story cache.
+func (s *HiringSource) Fetch(ctx context.Context) ([]feedkit.Item, []feedkit.ItemError, error) {
+	id, err := s.findThread(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	start := time.Now()
+	thread, err := getStory(ctx, s.API, id, s.Config.FetchTimeout)
+	feedkit.ObserveUpstream(SourceName, "getStory", start, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mu.Lock()
+	s.thread = thread
+	s.mu.Unlock()
+
+	log.Printf("Fetching %d jobs of hiring thread %d", len(thread.Kids), id)
+	comments, failures := lookupItems(ctx, s.API, s.Config, thread.Kids, s.Cache)
+	if feedkit.TooManyFailures(len(failures), len(thread.Kids), s.Config.MaxFailureRatio) {
+		return nil, failures, fmt.Errorf("%d of %d job lookups failed",
+			len(failures), len(thread.Kids))
+	}
+	feedkit.SetCacheSize(StoryCacheName, s.Cache.ItemCount())
+
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].Timestamp > comments[j].Timestamp
+	})
+	items := make([]feedkit.Item, 0, len(comments))
+	for _, comment := range comments {
+		if !comment.Removed() {
+			items = append(items, jobItem(parseJob(comment)))
+		}
+	}
+	return items, failures, nil
+}
+
+// JobFilter selects jobs based on the query parameters of a feed request,
+// e.g. "?remote=true&q=go&location=berlin"
+type JobFilter struct {
+	Remote   *bool
+	Keywords []*regexp.Regexp
+	Location string
+}
+
+func parseJobFilter(params url.Values) (JobFilter, error) {
+	var filter JobFilter
+	if value := params.Get("remote"); value != "" {
+		remote, err := strconv.ParseBool(value)
+		if err != nil {
+			return JobFilter{}, fmt.Errorf("invalid value for %q: %q", "remote", value)
+		}
+		filter.Remote = &remote
+	}
+	// Keywords match whole words, so that "go" does not match "google"
+	for _, keyword := range strings.Fields(params.Get("q")) {
+		filter.Keywords = append(filter.Keywords,
+			regexp.MustCompile(`(?i)(^|\W)`+regexp.QuoteMeta(keyword)+`($|\W)`))
+	}
+	filter.Location = strings.ToLower(strings.TrimSpace(params.Get("location")))
+	return filter, nil
+}
+
+func (f JobFilter) IsEmpty() bool {
+	return f.Remote == nil && len(f.Keywords) == 0 && f.Location == ""
+}
+
+func (f JobFilter) Match(job Job) bool {
+	if f.Remote != nil && job.Remote != *f.Remote {
+		return false
+	}
+	if f.Location != "" && !strings.Contains(strings.ToLower(job.Location), f.Location) {
+		return false
+	}
+	text := plainText(job.Comment.Text)
+	for _, keyword := range f.Keywords {
+		if !keyword.MatchString(text) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter narrows down the feed with the JobFilter query parameters
+func (s *HiringSource) Filter(params url.Values) (feedkit.ItemFilter, error) {
+	filter, err := parseJobFilter(params)
+	if err != nil || filter.IsEmpty() {
+		return nil, err
+	}
+	return func(item feedkit.Item) bool {
+		job, ok := item.Data.(Job)
+		return ok && filter.Match(job)
+	}, nil
+}
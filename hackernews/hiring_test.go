@@ -0,0 +1,150 @@
+package hackernews
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJob(t *testing.T) {
+	tests := []struct {
+		Name string
+		Text string
+		Want Job
+	}{
+		{
+			Name: "Full",
+			Text: "Acme Corp | Senior Go Engineer | Berlin, Germany | REMOTE (EU) | €80k-100k<p>We build rockets.",
+			Want: Job{Company: "Acme Corp", Role: "Senior Go Engineer", Location: "Berlin, Germany",
+				Remote: true, Salary: "€80k-100k"},
+		},
+		{
+			Name: "Terms",
+			Text: "<a href=\"https:&#x2F;&#x2F;example.com\">Example</a> | Backend Developer | Full-time | ONSITE | NYC | VISA<p>Apply!",
+			Want: Job{Company: "Example", Role: "Backend Developer", Location: "NYC",
+				Terms: []string{"Full-time", "ONSITE", "VISA"}},
+		},
+		{
+			Name: "RemoteLocation",
+			Text: "Foo &amp; Bar | SRE | London or Remote (UK)",
+			Want: Job{Company: "Foo & Bar", Role: "SRE", Location: "London or Remote (UK)", Remote: true},
+		},
+		{
+			Name: "NoHeader",
+			Text: "We are hiring engineers of all kinds.<p>Email us.",
+			Want: Job{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			job := parseJob(Story{ID: 1, Text: test.Text})
+			assert.Equal(t, test.Want.Company, job.Company)
+			assert.Equal(t, test.Want.Role, job.Role)
+			assert.Equal(t, test.Want.Location, job.Location)
+			assert.Equal(t, test.Want.Remote, job.Remote)
+			assert.Equal(t, test.Want.Salary, job.Salary)
+			assert.Equal(t, test.Want.Terms, job.Terms)
+		})
+	}
+
+	job := parseJob(Story{Text: "We are hiring engineers of all kinds.<p>Email us."})
+	assert.Equal(t, "We are hiring engineers of all kinds.", job.Title())
+	job = parseJob(Story{Text: tests[0].Text})
+	assert.Equal(t, "Acme Corp: Senior Go Engineer (Berlin, Germany)", job.Title())
+}
+
+func TestHiringSource(t *testing.T) {
+	srv := newItemServer(t, map[StoryID]Story{
+		99:  {ID: 99, Type: TypeStory, Title: "Ask HN: Who is hiring? (May 2021)", Timestamp: 1000},
+		100: {ID: 100, Type: TypeStory, Title: "Ask HN: Freelancer? Seeking freelancer? (June 2021)", Timestamp: 2000},
+		101: {ID: 101, Type: TypeStory, Title: "Ask HN: Who is hiring? (June 2021)", Timestamp: 2000,
+			Kids: []StoryID{1, 2, 3}},
+		1: {ID: 1, Type: TypeComment, Timestamp: 2100,
+			Text: "Acme Corp | Go Engineer | Berlin | REMOTE | €90k<p>We use Go and Postgres."},
+		2: {ID: 2, Type: TypeComment, Timestamp: 2200,
+			Text: "Initech | Java Developer | Austin, TX | ONSITE<p>Google Cloud experience a plus."},
+		3: {ID: 3, Type: TypeComment, Deleted: true, Timestamp: 2300},
+	})
+	defer srv.Close()
+	srv.SetUser(User{ID: HiringUser, Submitted: []StoryID{100, 101, 99}})
+
+	source := &HiringSource{
+		API:    srv.API,
+		Config: DefaultConfig(),
+		Cache:  cache.New(time.Minute, time.Minute),
+		Users:  cache.New(time.Minute, time.Minute),
+	}
+	items, failures, err := source.Fetch(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, failures)
+	assert.Equal(t, "Ask HN: Who is hiring? (June 2021)", source.Metadata().Title)
+	if !assert.Len(t, items, 2) {
+		return
+	}
+	assert.Equal(t, "Initech: Java Developer (Austin, TX)", items[0].Title)
+	assert.Equal(t, "https://news.ycombinator.com/item?id=2", items[0].Link)
+	assert.Equal(t, []string{RemoteCategory}, items[1].Categories)
+
+	filter := func(query string) []string {
+		params, _ := url.ParseQuery(query)
+		f, err := source.Filter(params)
+		assert.Nil(t, err)
+		titles := make([]string, 0)
+		for _, item := range items {
+			if f == nil || f(item) {
+				titles = append(titles, item.Data.(Job).Company)
+			}
+		}
+		return titles
+	}
+	assert.Equal(t, []string{"Initech", "Acme Corp"}, filter(""))
+	assert.Equal(t, []string{"Acme Corp"}, filter("remote=true&q=go&location=berlin"))
+	assert.Equal(t, []string{"Initech"}, filter("remote=false"))
+	// Keywords match whole words
+	assert.Equal(t, []string{"Acme Corp"}, filter("q=go"))
+	assert.Equal(t, []string{"Initech"}, filter("q=google+cloud"))
+
+	_, err = source.Filter(url.Values{"remote": {"maybe"}})
+	assert.NotNil(t, err)
+
+	t.Run("ConcurrentServe", func(t *testing.T) {
+		// Filtered requests build the feed, reading the thread, while it
+		// is refreshed
+		feed := feedkit.NewFeed("/hiring", source, feedkit.NewFeedConfig())
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				_, err := feed.Refresh(context.Background())
+				assert.Nil(t, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				rr := httptest.NewRecorder()
+				feed.ServeHTTP(rr, httptest.NewRequest("GET", "/hiring?remote=true", nil))
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Contains(t, rr.Body.String(), "Ask HN: Who is hiring? (June 2021)")
+			}
+		}()
+		wg.Wait()
+	})
+
+	t.Run("NoThread", func(t *testing.T) {
+		srv.SetUser(User{ID: HiringUser, Submitted: []StoryID{100}})
+		source.Users.Flush()
+		_, _, err := source.Fetch(context.Background())
+		assert.NotNil(t, err)
+	})
+}
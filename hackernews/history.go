@@ -0,0 +1,148 @@
+package hackernews
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+)
+
+// Store bucket holding every story fetched
+const storiesBucket = "stories"
+
+// storyListBucket is the store bucket recording when stories were first
+// seen on a story list
+func storyListBucket(list StoryList) string {
+	return "list:" + list.Name
+}
+
+func storyKey(id StoryID) string {
+	return strconv.Itoa(int(id))
+}
+
+// loadStories fills the story cache from the store, dropping stories first
+// seen longer than StoryRetention ago
+func loadStories(store feedkit.Store, storyCache *cache.Cache) error {
+	expired := make([]string, 0)
+	count := 0
+	err := store.ForEach(storiesBucket, func(key string, data []byte) error {
+		var story Story
+		if err := json.Unmarshal(data, &story); err != nil {
+			return err
+		}
+		if time.Since(story.FirstSeen) > StoryRetention {
+			expired = append(expired, key)
+			return nil
+		}
+		storyCache.Set(key, story, cache.NoExpiration)
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Loaded %d stories from store, expired %d", count, len(expired))
+	return store.Delete(storiesBucket, expired...)
+}
+
+func saveStories(store feedkit.Store, stories []Story) error {
+	records := make(map[string]interface{}, len(stories))
+	for _, story := range stories {
+		records[storyKey(story.ID)] = story
+	}
+	return store.Put(storiesBucket, records)
+}
+
+// flushStories saves every story in the story cache to the store
+func flushStories(store feedkit.Store, storyCache *cache.Cache) error {
+	stories := make([]Story, 0, storyCache.ItemCount())
+	for _, item := range storyCache.Items() {
+		if story, ok := item.Object.(Story); ok {
+			stories = append(stories, story)
+		}
+	}
+	log.Printf("Flushing %d stories to store", len(stories))
+	return saveStories(store, stories)
+}
+
+// storyHistory adds the stories seen on the list in the last HistoryAge to
+// the current list of story IDs, so that stories stay in the feed for a
+// while after they drop off the list. The feed is capped at HistorySize
+// stories, or the length of the current list if that is longer.
+func storyHistory(store feedkit.Store, list StoryList, ids []StoryID, now time.Time) ([]StoryID, error) {
+	bucket := storyListBucket(list)
+	firstSeen := make(map[StoryID]time.Time)
+	err := store.ForEach(bucket, func(key string, data []byte) error {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return err
+		}
+		var seen time.Time
+		if err := json.Unmarshal(data, &seen); err != nil {
+			return err
+		}
+		firstSeen[StoryID(id)] = seen
+		return nil
+	})
+	if err != nil {
+		return ids, err
+	}
+
+	current := make(map[StoryID]bool, len(ids))
+	added := make(map[string]interface{})
+	for _, id := range ids {
+		current[id] = true
+		if _, found := firstSeen[id]; !found {
+			firstSeen[id] = now
+			added[storyKey(id)] = now
+		}
+	}
+
+	retained := make([]StoryID, 0)
+	expired := make([]string, 0)
+	for id, seen := range firstSeen {
+		if current[id] {
+			continue
+		}
+		if now.Sub(seen) > HistoryAge {
+			expired = append(expired, storyKey(id))
+			continue
+		}
+		retained = append(retained, id)
+	}
+
+	// Keep the most recently seen stories
+	sort.Slice(retained, func(i, j int) bool {
+		a, b := firstSeen[retained[i]], firstSeen[retained[j]]
+		if a.Equal(b) {
+			return retained[i] > retained[j]
+		}
+		return a.After(b)
+	})
+	keep := HistorySize - len(ids)
+	if keep < 0 {
+		keep = 0
+	}
+	if keep < len(retained) {
+		for _, id := range retained[keep:] {
+			expired = append(expired, storyKey(id))
+		}
+		retained = retained[:keep]
+	}
+
+	if err := store.Put(bucket, added); err != nil {
+		return ids, err
+	}
+	if err := store.Delete(bucket, expired...); err != nil {
+		return ids, err
+	}
+
+	history := make([]StoryID, 0, len(ids)+len(retained))
+	history = append(history, ids...)
+	return append(history, retained...), nil
+}
@@ -0,0 +1,75 @@
+package hackernews
+
+import (
+	"testing"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoryHistory(t *testing.T) {
+	store := feedkit.NewMemoryStore()
+	start := time.Date(2021, time.May, 24, 10, 0, 0, 0, time.UTC)
+
+	ids, err := storyHistory(store, BestStories, []StoryID{1, 2, 3}, start)
+	assert.Nil(t, err)
+	assert.Equal(t, []StoryID{1, 2, 3}, ids)
+
+	// Stories which dropped off the list are kept in the feed
+	ids, err = storyHistory(store, BestStories, []StoryID{3, 4}, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, []StoryID{3, 4, 2, 1}, ids)
+
+	// Other lists have their own history
+	ids, err = storyHistory(store, NewStories, []StoryID{5}, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, []StoryID{5}, ids)
+
+	// ... until they are older than HistoryAge
+	ids, err = storyHistory(store, BestStories, []StoryID{4}, start.Add(HistoryAge+time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, []StoryID{4}, ids)
+}
+
+func TestLoadStories(t *testing.T) {
+	store := feedkit.NewMemoryStore()
+	stories := []Story{
+		{ID: 1, Title: "Recent", FirstSeen: time.Now().Add(-time.Hour)},
+		{ID: 2, Title: "Expired", FirstSeen: time.Now().Add(-StoryRetention - time.Hour)},
+	}
+	assert.Nil(t, saveStories(store, stories))
+
+	storyCache := cache.New(0, 0)
+	assert.Nil(t, loadStories(store, storyCache))
+
+	story, found := storyCache.Get("1")
+	assert.True(t, found)
+	assert.Equal(t, "Recent", story.(Story).Title)
+	assert.True(t, stories[0].FirstSeen.Equal(story.(Story).FirstSeen))
+
+	_, found = storyCache.Get("2")
+	assert.False(t, found)
+
+	// Expired stories are removed from the store
+	storyCache = cache.New(0, 0)
+	assert.Nil(t, loadStories(store, storyCache))
+	assert.Equal(t, 1, storyCache.ItemCount())
+}
+
+func TestFlushStories(t *testing.T) {
+	store := feedkit.NewMemoryStore()
+	storyCache := cache.New(0, 0)
+	for _, story := range []Story{
+		{ID: 1, Title: "One", FirstSeen: time.Now()},
+		{ID: 2, Title: "Two", FirstSeen: time.Now()},
+	} {
+		storyCache.Set(storyKey(story.ID), story, cache.NoExpiration)
+	}
+	assert.Nil(t, flushStories(store, storyCache))
+
+	loaded := cache.New(0, 0)
+	assert.Nil(t, loadStories(store, loaded))
+	assert.Equal(t, 2, loaded.ItemCount())
+}
@@ -0,0 +1,204 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+)
+
+// CommentsSource is the feed of the comments on a single story, served on
+// /item/{id}/comments. It remembers the comments it has seen, so a refresh
+// only walks the thread again once the story has new comments, and then
+// only looks up the comments it has not seen.
+type CommentsSource struct {
+	API    HackerNewsAPI
+	Config Config
+	Cache  *cache.Cache // Item cache, shared with the other item feeds
+	ID     StoryID
+
+	mu    sync.Mutex // Guards story, which Metadata reads during refreshes
+	story Story
+
+	walkMu      sync.Mutex        // Serializes refreshes, which walk the thread
+	descendants int               // Number of comments as of the last walk
+	comments    map[StoryID]Story // Comments seen in the thread
+}
+
+// newCommentsSource builds the source of an item feed path
+func newCommentsSource(api HackerNewsAPI, config Config, itemCache *cache.Cache) feedkit.NewSourceFunc {
+	return func(key string) (feedkit.Source, error) {
+		parts := strings.Split(key, "/")
+		if len(parts) != 2 || parts[1] != "comments" {
+			return nil, fmt.Errorf("no feed at %q: %w", key, feedkit.ErrNotFound)
+		}
+		id, err := strconv.Atoi(parts[0])
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid item ID %q: %w", parts[0], feedkit.ErrNotFound)
+		}
+		return &CommentsSource{
+			API:    api,
+			Config: config,
+			Cache:  itemCache,
+			ID:     StoryID(id),
+		}, nil
+	}
+}
+
+func (s *CommentsSource) Metadata() feedkit.Metadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return feedkit.Metadata{
+		Title:       "Comments on " + s.story.Title,
+		Link:        fmt.Sprintf(HNSourceURL, s.ID),
+		Description: fmt.Sprintf("Hacker News comments on %q", s.story.Title),
+	}
+}
+
+// commentItem builds the feed entry of a comment, with the title of the
+// story it is on if known
+func commentItem(comment Story, on string) feedkit.Item {
+	link := fmt.Sprintf(HNSourceURL, comment.ID)
+	title := "Comment by " + comment.By
+	if on != "" {
+		title += " on " + on
+	}
+	return feedkit.Item{
+		ID:          link,
+		Title:       title,
+		Link:        link,
+		Source:      link,
+		Description: comment.Text,
+		Created:     comment.Time(),
+		Data:        comment,
+	}
+}
+
+// seenComment returns a comment seen in the thread before. The copy in the
+// item cache is preferred, as the updates poller looks up the cached items
+// which changed, e.g. the comments with new replies.
+func (s *CommentsSource) seenComment(id StoryID) (Story, bool) {
+	comment, seen := s.comments[id]
+	if !seen {
+		return Story{}, false
+	}
+	if cached, found := s.Cache.Get(storyKey(id)); found {
+		return cached.(Story), true
+	}
+	return comment, true
+}
+
+// walkThread looks up the comments on the story, one level of replies at
+// a time. Only the comments which were not seen before are looked up,
+// unless refetch is set, in which case the seen comments are looked up
+// again, bypassing the item cache, to find new replies to them.
+func (s *CommentsSource) walkThread(ctx context.Context, story Story, refetch bool) (map[StoryID]Story, []feedkit.ItemError) {
+	comments := make(map[StoryID]Story)
+	failures := make([]feedkit.ItemError, 0)
+	for ids := story.Kids; len(ids) > 0; {
+		next := make([]StoryID, 0)
+		unseen := make([]StoryID, 0, len(ids))
+		for _, id := range ids {
+			comment, seen := s.seenComment(id)
+			switch {
+			case seen && refetch:
+				s.Cache.Delete(storyKey(id))
+				unseen = append(unseen, id)
+			case seen:
+				comments[id] = comment
+				next = append(next, comment.Kids...)
+			default:
+				unseen = append(unseen, id)
+			}
+		}
+		found, failed := lookupItems(ctx, s.API, s.Config, unseen, s.Cache)
+		failures = append(failures, failed...)
+
+		for _, comment := range found {
+			comments[comment.ID] = comment
+			// Replies to removed comments are kept
+			next = append(next, comment.Kids...)
+		}
+		ids = next
+	}
+	return comments, failures
+}
+
+// Fetch returns the comments on the story, newest first. The story is
+// looked up on every refresh, but its comments only when its number of
+// comments has changed since the last one. New replies to the comments
+// seen before are found through the updates poller; with updates disabled,
+// the seen comments are looked up again when the thread is short of
+// comments.
+func (s *CommentsSource) Fetch(ctx context.Context) ([]feedkit.Item, []feedkit.ItemError, error) {
+	s.walkMu.Lock()
+	defer s.walkMu.Unlock()
+
+	start := time.Now()
+	story, err := getStory(ctx, s.API, s.ID, s.Config.FetchTimeout)
+	feedkit.ObserveUpstream(SourceName, "getStory", start, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	if story.Removed() {
+		return nil, nil, fmt.Errorf("item %d was removed: %w", s.ID, feedkit.ErrNotFound)
+	}
+
+	s.mu.Lock()
+	s.story = story
+	s.mu.Unlock()
+
+	failures := make([]feedkit.ItemError, 0)
+	if s.comments == nil || story.Descendants != s.descendants {
+		log.Printf("Fetching comments on item %d", s.ID)
+		comments, failed := s.walkThread(ctx, story, false)
+		if len(failed) == 0 && s.Config.UpdateInterval == 0 && len(comments) < story.Descendants {
+			log.Printf("Fetching comments on item %d again for new replies", s.ID)
+			comments, failed = s.walkThread(ctx, story, true)
+		}
+		feedkit.SetCacheSize(ItemCacheName, s.Cache.ItemCount())
+		failures = failed
+		total := len(comments) + len(failures)
+		if feedkit.TooManyFailures(len(failures), total, s.Config.MaxFailureRatio) {
+			return nil, failures, fmt.Errorf("%d of %d comment lookups failed",
+				len(failures), total)
+		}
+		// Comments which could not be looked up again are kept, and the
+		// thread is walked again on the next refresh
+		for _, failure := range failures {
+			id, _ := strconv.Atoi(failure.Item)
+			if comment, seen := s.comments[StoryID(id)]; seen {
+				comments[comment.ID] = comment
+			}
+		}
+		s.comments = comments
+		if len(failures) == 0 {
+			s.descendants = story.Descendants
+		}
+	}
+
+	items := make([]feedkit.Item, 0, len(s.comments))
+	for _, comment := range s.comments {
+		if comment.Removed() {
+			continue
+		}
+		items = append(items, commentItem(comment, story.Title))
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Created.Equal(items[j].Created) {
+			return items[i].ID > items[j].ID
+		}
+		return items[i].Created.After(items[j].Created)
+	})
+	if len(items) > HistorySize {
+		items = items[:HistorySize]
+	}
+	return items, failures, nil
+}
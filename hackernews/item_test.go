@@ -0,0 +1,135 @@
+package hackernews
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommentsSource(t *testing.T) {
+	srv := newItemServer(t, map[StoryID]Story{
+		1: {ID: 1, Title: "Launch", Timestamp: 1000, Descendants: 3, Kids: []StoryID{2, 3}},
+		2: {ID: 2, By: "alice", Text: "First", Timestamp: 1060, Kids: []StoryID{4}},
+		3: {ID: 3, Type: "comment", Deleted: true, Timestamp: 1120},
+		4: {ID: 4, By: "carol", Text: "Reply", Timestamp: 1180},
+	})
+	defer srv.Close()
+
+	itemCache := NewItemCache(DefaultConfig())
+	newSource := newCommentsSource(srv.API, DefaultConfig(), itemCache)
+	source, err := newSource("1/comments")
+	assert.Nil(t, err)
+
+	titles := func(items []feedkit.Item) []string {
+		titles := make([]string, 0, len(items))
+		for _, item := range items {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+
+	items, failures, err := source.Fetch(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, failures)
+	assert.Equal(t, []string{"Comment by carol on Launch", "Comment by alice on Launch"}, titles(items))
+	assert.Equal(t, "https://news.ycombinator.com/item?id=4", items[0].ID)
+	assert.Equal(t, "Comments on Launch", source.Metadata().Title)
+	// Comments expire from the item cache
+	_, expires, found := itemCache.GetWithExpiration("2")
+	assert.True(t, found)
+	assert.False(t, expires.IsZero())
+
+	// Comments are not looked up again until the story has new ones
+	_, _, err = source.Fetch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, srv.Lookups(1))
+	assert.Equal(t, 1, srv.Lookups(2))
+
+	// The updates poller looks up the comment with a new reply, and only
+	// the reply is looked up by the source
+	srv.Set(Story{ID: 1, Title: "Launch", Timestamp: 1000, Descendants: 4, Kids: []StoryID{2, 3}})
+	srv.Set(Story{ID: 4, By: "carol", Text: "Reply", Timestamp: 1180, Kids: []StoryID{5}})
+	srv.Set(Story{ID: 5, By: "dave", Text: "Late reply", Timestamp: 5000})
+	updatesSrv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(Updates{Items: []StoryID{4}})
+		}))
+	defer updatesSrv.Close()
+	api := srv.API
+	api.Updates = updatesSrv.URL
+	_, err = updateStories(context.Background(), api, DefaultConfig(), cache.New(0, 0), itemCache, cache.New(time.Minute, time.Minute))
+	assert.Nil(t, err)
+
+	items, _, err = source.Fetch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "Comment by dave on Launch", items[0].Title)
+	assert.Len(t, items, 3)
+	assert.Equal(t, 3, srv.Lookups(1))
+	assert.Equal(t, 1, srv.Lookups(2))
+	assert.Equal(t, 1, srv.Lookups(3))
+	assert.Equal(t, 2, srv.Lookups(4))
+	assert.Equal(t, 1, srv.Lookups(5))
+
+	t.Run("UpdatesDisabled", func(t *testing.T) {
+		// Without updates, the seen comments are looked up again once the
+		// thread is short of comments
+		config := DefaultConfig()
+		config.UpdateInterval = 0
+		source, err := newCommentsSource(srv.API, config, cache.New(time.Minute, time.Minute))("1/comments")
+		assert.Nil(t, err)
+		_, _, err = source.Fetch(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 2, srv.Lookups(2))
+
+		srv.Set(Story{ID: 1, Title: "Launch", Timestamp: 1000, Descendants: 5, Kids: []StoryID{2, 3}})
+		srv.Set(Story{ID: 5, By: "dave", Text: "Late reply", Timestamp: 5000, Kids: []StoryID{6}})
+		srv.Set(Story{ID: 6, By: "erin", Text: "Later reply", Timestamp: 6000})
+		items, _, err := source.Fetch(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "Comment by erin on Launch", items[0].Title)
+		assert.Len(t, items, 4)
+		assert.Equal(t, 3, srv.Lookups(2))
+		assert.Equal(t, 1, srv.Lookups(6))
+	})
+
+	t.Run("ConcurrentMetadata", func(t *testing.T) {
+		// Metadata is read while serving filtered requests, concurrently
+		// with refreshes
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				source.Fetch(context.Background())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				assert.Equal(t, "Comments on Launch", source.Metadata().Title)
+			}
+		}()
+		wg.Wait()
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		for _, key := range []string{"1", "1/replies", "x/comments", "0/comments"} {
+			_, err := newSource(key)
+			assert.True(t, errors.Is(err, feedkit.ErrNotFound), key)
+		}
+
+		source, err := newSource("99/comments")
+		assert.Nil(t, err)
+		_, _, err = source.Fetch(context.Background())
+		assert.True(t, errors.Is(err, feedkit.ErrNotFound))
+	})
+}
@@ -0,0 +1,63 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	JobAuthor   = "YC job" // Author of job entries
+	JobCategory = "job"    // Category of job entries
+)
+
+// getPollOptions looks up the options of the polls among the stories,
+// through the story cache. Options are returned in the order of the poll.
+func getPollOptions(ctx context.Context, api HackerNewsAPI, config Config, stories []Story, storyCache *cache.Cache) (map[StoryID][]Story, []feedkit.ItemError) {
+	ids := make([]StoryID, 0)
+	for _, story := range stories {
+		if story.Type == TypePoll {
+			ids = append(ids, story.Parts...)
+		}
+	}
+	options := make(map[StoryID][]Story)
+	if len(ids) == 0 {
+		return options, nil
+	}
+
+	found, failures := lookupItems(ctx, api, config, ids, storyCache)
+	byID := make(map[StoryID]Story, len(found))
+	for _, option := range found {
+		byID[option.ID] = option
+	}
+	for _, story := range stories {
+		if story.Type != TypePoll {
+			continue
+		}
+		for _, id := range story.Parts {
+			if option, ok := byID[id]; ok && !option.Removed() {
+				options[story.ID] = append(options[story.ID], option)
+			}
+		}
+	}
+	return options, failures
+}
+
+// renderPoll renders the options of a poll and their votes as an HTML
+// table
+func renderPoll(options []Story) string {
+	if len(options) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<table><tr><th>Option</th><th>Votes</th></tr>")
+	for _, option := range options {
+		// Option texts are HTML already
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%d</td></tr>", option.Text, option.Score)
+	}
+	b.WriteString("</table>")
+	return b.String()
+}
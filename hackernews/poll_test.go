@@ -0,0 +1,44 @@
+package hackernews
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollOptions(t *testing.T) {
+	srv := newItemServer(t, map[StoryID]Story{
+		11: {ID: 11, Type: TypePollOpt, Poll: 10, Text: "Vim", Score: 42},
+		12: {ID: 12, Type: TypePollOpt, Poll: 10, Text: "Emacs", Score: 40},
+		13: {ID: 13, Type: TypePollOpt, Poll: 10, Deleted: true},
+	})
+	defer srv.Close()
+	srv.Fail(14)
+
+	stories := []Story{
+		{ID: 1, Type: TypeStory, Title: "Story"},
+		{ID: 10, Type: TypePoll, Title: "Poll: Editors", Parts: []StoryID{12, 11, 13, 14}},
+	}
+	options, failures := getPollOptions(context.Background(), srv.API, DefaultConfig(), stories, cache.New(time.Minute, time.Minute))
+	assert.Len(t, failures, 1)
+	assert.Empty(t, options[1])
+
+	// Options keep the order of the poll
+	assert.Equal(t, "<table><tr><th>Option</th><th>Votes</th></tr>"+
+		"<tr><td>Emacs</td><td>40</td></tr>"+
+		"<tr><td>Vim</td><td>42</td></tr></table>", renderPoll(options[10]))
+	assert.Equal(t, "", renderPoll(nil))
+}
+
+func TestJobItem(t *testing.T) {
+	item := storyItem(Story{ID: 1, Type: TypeJob, Title: "Acme is hiring"})
+	assert.Equal(t, JobAuthor, item.Author)
+	assert.Equal(t, []string{JobCategory}, item.Categories)
+
+	item = storyItem(Story{ID: 2, Type: TypeStory, Title: "Story"})
+	assert.Empty(t, item.Author)
+	assert.Empty(t, item.Categories)
+}
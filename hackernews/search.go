@@ -0,0 +1,245 @@
+package hackernews
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+)
+
+const (
+	HNSearchURL   = "https://hn.algolia.com/?%s"
+	SearchResults = 50      // Results per search feed
+	SearchTags    = "story" // Default tags of searches
+)
+
+var (
+	// Numeric conditions of a search, e.g. "points>50". Since "=" separates
+	// query parameters, "points>=50" arrives as "points>" set to "50".
+	numericRE = regexp.MustCompile(`^(points|comments|num_comments|created_at_i)(>=|<=|>|<)(\d+)$`)
+	tagsRE    = regexp.MustCompile(`^[A-Za-z0-9_,()-]+$`)
+
+	// Algolia names of the numeric attributes
+	numericAttributes = map[string]string{
+		"comments": "num_comments",
+	}
+)
+
+// searchHit is a result of the Algolia Hacker News search API
+type searchHit struct {
+	ObjectID    string   `json:"objectID"`
+	Tags        []string `json:"_tags"`
+	Author      string   `json:"author"`
+	Points      int      `json:"points"`
+	NumComments int      `json:"num_comments"`
+	CreatedAt   int64    `json:"created_at_i"`
+	Title       string   `json:"title"`
+	URL         string   `json:"url"`
+	StoryText   string   `json:"story_text"`
+	CommentText string   `json:"comment_text"`
+	ParentID    StoryID  `json:"parent_id"`
+	StoryTitle  string   `json:"story_title"` // Comments
+}
+
+// Story maps the hit to the item the Firebase API serves, so that search
+// results are filtered and rendered like the story lists
+func (h searchHit) Story() (Story, error) {
+	id, err := strconv.Atoi(h.ObjectID)
+	if err != nil {
+		return Story{}, fmt.Errorf("invalid search hit ID %q", h.ObjectID)
+	}
+	story := Story{
+		ID:          StoryID(id),
+		By:          h.Author,
+		Score:       h.Points,
+		Descendants: h.NumComments,
+		Timestamp:   h.CreatedAt,
+		Title:       h.Title,
+		URL:         h.URL,
+		Text:        h.StoryText,
+	}
+	for _, tag := range h.Tags {
+		switch tag {
+		case TypeStory, TypeJob, TypePoll, TypePollOpt, TypeComment:
+			story.Type = tag
+		}
+	}
+	if story.Type == TypeComment {
+		story.Text = h.CommentText
+		story.Parent = h.ParentID
+	}
+	return story, nil
+}
+
+type searchResults struct {
+	Hits []searchHit `json:"hits"`
+}
+
+// getSearch returns the newest items matching an Algolia query
+func getSearch(ctx context.Context, api HackerNewsAPI, query url.Values, timeout time.Duration) ([]searchHit, error) {
+	var results searchResults
+
+	params := url.Values{"hitsPerPage": {strconv.Itoa(SearchResults)}}
+	for name, values := range query {
+		params[name] = values
+	}
+
+	url := fmt.Sprintf(api.Search, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{
+		Timeout: timeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, fmt.Errorf("%s: %v", url, err)
+	}
+	return results.Hits, nil
+}
+
+// searchKey names the search feed of a request by its Algolia query, e.g.
+// "numericFilters=points%3E50&query=golang&tags=story" for
+// "/search?q=golang&points>50", so that requests for the same search share
+// a feed whatever the order of their parameters
+func searchKey(req *http.Request) (string, error) {
+	params := req.URL.Query()
+	q := strings.TrimSpace(params.Get("q"))
+	if q == "" {
+		return "", fmt.Errorf("missing search query %q: %w", "q", feedkit.ErrBadRequest)
+	}
+	tags := params.Get("tags")
+	if tags == "" {
+		tags = SearchTags
+	}
+	if !tagsRE.MatchString(tags) {
+		return "", fmt.Errorf("invalid value for %q: %q: %w", "tags", tags, feedkit.ErrBadRequest)
+	}
+
+	filters := make([]string, 0)
+	for name, values := range params {
+		for _, value := range values {
+			condition := name
+			if value != "" {
+				condition += "=" + value
+			}
+			tokens := numericRE.FindStringSubmatch(condition)
+			if tokens == nil {
+				continue
+			}
+			attribute := tokens[1]
+			if alias, ok := numericAttributes[attribute]; ok {
+				attribute = alias
+			}
+			filters = append(filters, attribute+tokens[2]+tokens[3])
+		}
+	}
+	sort.Strings(filters)
+
+	query := url.Values{"query": {q}, "tags": {tags}}
+	if len(filters) > 0 {
+		query.Set("numericFilters", strings.Join(filters, ","))
+	}
+	return query.Encode(), nil
+}
+
+// SearchSource is the feed of the newest items matching a search of the
+// Algolia Hacker News search API, served on /search
+type SearchSource struct {
+	API    HackerNewsAPI
+	Config Config
+	Query  url.Values // Algolia query parameters
+}
+
+// newSearchSource builds the source of a search named by searchKey
+func newSearchSource(api HackerNewsAPI, config Config) feedkit.NewSourceFunc {
+	return func(key string) (feedkit.Source, error) {
+		query, err := url.ParseQuery(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid search %q: %w", key, feedkit.ErrBadRequest)
+		}
+		return &SearchSource{
+			API:    api,
+			Config: config,
+			Query:  query,
+		}, nil
+	}
+}
+
+func (s *SearchSource) Metadata() feedkit.Metadata {
+	q := s.Query.Get("query")
+	return feedkit.Metadata{
+		Title:       "Hacker News search: " + q,
+		Link:        fmt.Sprintf(HNSearchURL, url.Values{"query": {q}, "sort": {"byDate"}}.Encode()),
+		Description: fmt.Sprintf("Newest Hacker News items matching %q", q),
+	}
+}
+
+// Fetch returns the newest items matching the search. Hits carry the
+// fields of their items, so no items are looked up.
+func (s *SearchSource) Fetch(ctx context.Context) ([]feedkit.Item, []feedkit.ItemError, error) {
+	start := time.Now()
+	hits, err := getSearch(ctx, s.API, s.Query, s.Config.FetchTimeout)
+	feedkit.ObserveUpstream(SourceName, "search", start, err)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := make([]feedkit.Item, 0, len(hits))
+	failures := make([]feedkit.ItemError, 0)
+	for _, hit := range hits {
+		story, err := hit.Story()
+		if err != nil {
+			failures = append(failures, feedkit.ItemError{Item: hit.ObjectID, Error: err.Error()})
+			continue
+		}
+		if story.Type == TypeComment {
+			items = append(items, commentItem(story, hit.StoryTitle))
+		} else {
+			items = append(items, storyItem(story))
+		}
+	}
+	return items, failures, nil
+}
+
+// Filter narrows down the feed with the StoryFilter query parameters. The
+// keywords of the search are matched by Algolia, so "q" is not a filter.
+func (s *SearchSource) Filter(params url.Values) (feedkit.ItemFilter, error) {
+	filters := make(url.Values, len(params))
+	for name, values := range params {
+		if name != "q" {
+			filters[name] = values
+		}
+	}
+	filter, err := parseStoryFilter(filters)
+	if err != nil || filter.IsEmpty() {
+		return nil, err
+	}
+	return func(item feedkit.Item) bool {
+		story, ok := item.Data.(Story)
+		return ok && filter.Match(story)
+	}, nil
+}
@@ -0,0 +1,120 @@
+package hackernews
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchKey(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Query string
+		Want  url.Values
+	}{
+		{"Query", "q=golang", url.Values{
+			"query": {"golang"}, "tags": {"story"},
+		}},
+		{"Tags", "q=golang&tags=comment", url.Values{
+			"query": {"golang"}, "tags": {"comment"},
+		}},
+		{"Numeric", "q=golang&points>50&comments>=10&created_at_i<1700000000", url.Values{
+			"query":          {"golang"},
+			"tags":           {"story"},
+			"numericFilters": {"created_at_i<1700000000,num_comments>=10,points>50"},
+		}},
+		{"Filters", "q=golang&points=100&domain=github.com", url.Values{
+			"query": {"golang"}, "tags": {"story"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/search?"+test.Query, nil)
+			key, err := searchKey(req)
+			assert.Nil(t, err)
+			assert.Equal(t, test.Want.Encode(), key)
+		})
+	}
+
+	t.Run("Canonical", func(t *testing.T) {
+		a, _ := searchKey(httptest.NewRequest("GET", "/search?points>5&q=go&comments>1", nil))
+		b, _ := searchKey(httptest.NewRequest("GET", "/search?comments>1&q=go&points>5", nil))
+		assert.Equal(t, a, b)
+	})
+
+	for _, query := range []string{"", "q=", "q=go&tags=story%26x"} {
+		t.Run("Invalid"+query, func(t *testing.T) {
+			_, err := searchKey(httptest.NewRequest("GET", "/search?"+query, nil))
+			assert.True(t, errors.Is(err, feedkit.ErrBadRequest))
+		})
+	}
+}
+
+func TestSearchSource(t *testing.T) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hits": [
+			{"objectID": "3", "_tags": ["story", "author_bob", "story_3", "show_hn"],
+			 "author": "bob", "points": 120, "num_comments": 40, "created_at_i": 3000,
+			 "title": "Show HN: Go thing", "url": "https://github.com/bob/thing",
+			 "story_text": null},
+			{"objectID": "2", "_tags": ["comment", "author_carol", "story_1"],
+			 "author": "carol", "points": null, "created_at_i": 2000,
+			 "comment_text": "Go is great", "parent_id": 1,
+			 "story_title": "Why Go"},
+			{"objectID": "1", "_tags": ["story", "author_alice", "story_1"],
+			 "author": "alice", "points": 10, "num_comments": 1, "created_at_i": 1000,
+			 "title": "Why Go", "story_text": "Some text"}
+		]}`))
+	}))
+	defer srv.Close()
+	api := HackerNewsAPI{Search: srv.URL + "/search_by_date?%s"}
+
+	source, err := newSearchSource(api, DefaultConfig())("numericFilters=points%3E5&query=go&tags=story")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, failures, err := source.Fetch(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, failures)
+	assert.Equal(t, "go", query.Get("query"))
+	assert.Equal(t, "story", query.Get("tags"))
+	assert.Equal(t, "points>5", query.Get("numericFilters"))
+	assert.Equal(t, "50", query.Get("hitsPerPage"))
+
+	if assert.Len(t, items, 3) {
+		story := items[0].Data.(Story)
+		assert.Equal(t, Story{
+			ID: 3, Type: TypeStory, By: "bob", Score: 120, Descendants: 40,
+			Timestamp: 3000, Title: "Show HN: Go thing", URL: "https://github.com/bob/thing",
+		}, story)
+		assert.Equal(t, "https://github.com/bob/thing", items[0].Link)
+		assert.Equal(t, "Comment by carol on Why Go", items[1].Title)
+		assert.Equal(t, "Go is great", items[1].Description)
+		assert.Equal(t, StoryID(1), items[1].Data.(Story).Parent)
+		assert.Equal(t, "https://news.ycombinator.com/item?id=1", items[2].Link)
+	}
+
+	t.Run("Filter", func(t *testing.T) {
+		// "q" is matched by the search, not the filter
+		filter, err := source.(feedkit.Filterer).Filter(url.Values{"q": {"rust"}})
+		assert.Nil(t, err)
+		assert.Nil(t, filter)
+
+		filter, err = source.(feedkit.Filterer).Filter(url.Values{"q": {"go"}, "domain": {"github.com"}})
+		assert.Nil(t, err)
+		if assert.NotNil(t, filter) {
+			assert.True(t, filter(items[0]))
+			assert.False(t, filter(items[2]))
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package hackernews
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+)
+
+// Updates lists the items and profiles which changed recently
+type Updates struct {
+	Items    []StoryID `json:"items"`
+	Profiles []string  `json:"profiles"`
+}
+
+func getUpdates(ctx context.Context, api HackerNewsAPI, timeout time.Duration) (Updates, error) {
+	var updates Updates
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api.Updates, nil)
+	if err != nil {
+		return Updates{}, err
+	}
+	client := http.Client{
+		Timeout: timeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Updates{}, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Updates{}, fmt.Errorf("%s: %s", api.Updates, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Updates{}, err
+	}
+
+	if err := json.Unmarshal(body, &updates); err != nil {
+		return Updates{}, fmt.Errorf("%s: %v", api.Updates, err)
+	}
+	return updates, nil
+}
+
+// updateStories looks up the cached items which changed since they were
+// cached, in the story cache and in the item cache, and drops the changed
+// users from the user cache. It returns the number of items updated.
+func updateStories(ctx context.Context, api HackerNewsAPI, config Config, storyCache *cache.Cache, itemCache *cache.Cache, userCache *cache.Cache) (int, error) {
+	start := time.Now()
+	updates, err := getUpdates(ctx, api, config.FetchTimeout)
+	feedkit.ObserveUpstream(SourceName, "getUpdates", start, err)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, username := range updates.Profiles {
+		userCache.Delete(username)
+	}
+
+	updated := 0
+	for _, c := range []*cache.Cache{storyCache, itemCache} {
+		updated += updateItems(ctx, api, config, c, updates.Items)
+	}
+	if updated > 0 {
+		log.Printf("Updated %d of %d changed items", updated, len(updates.Items))
+	}
+	return updated, nil
+}
+
+// updateItems looks up the changed items of a cache again, keeping when
+// they were first seen. It returns the number of items updated.
+func updateItems(ctx context.Context, api HackerNewsAPI, config Config, itemCache *cache.Cache, changed []StoryID) int {
+	// Changed items are evicted, looked up again and put back with the
+	// time they were first seen
+	cached := make(map[StoryID]Story)
+	ids := make([]StoryID, 0)
+	for _, id := range changed {
+		key := storyKey(id)
+		if story, found := itemCache.Get(key); found {
+			cached[id] = story.(Story)
+			ids = append(ids, id)
+			itemCache.Delete(key)
+		}
+	}
+	if len(ids) == 0 {
+		return 0
+	}
+
+	stories, failures := lookupItems(ctx, api, config, ids, itemCache)
+	for _, story := range stories {
+		story.FirstSeen = cached[story.ID].FirstSeen
+		itemCache.SetDefault(storyKey(story.ID), story)
+	}
+	// Items which could not be looked up keep their previous version
+	for _, failure := range failures {
+		id, _ := strconv.Atoi(failure.Item)
+		itemCache.SetDefault(failure.Item, cached[StoryID(id)])
+	}
+	return len(stories)
+}
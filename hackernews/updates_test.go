@@ -0,0 +1,88 @@
+package hackernews
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateStories(t *testing.T) {
+	srv := newItemServer(t, map[StoryID]Story{
+		1: {ID: 1, Title: "Renamed", Score: 50, Descendants: 12, Timestamp: 1000},
+		2: {ID: 2, Title: "Killed", Score: 1, Timestamp: 1000, Dead: true},
+		3: {ID: 3, Title: "Edited", Timestamp: 1000},
+	})
+	defer srv.Close()
+	srv.Fail(4)
+
+	updatesSrv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(Updates{
+				Items:    []StoryID{1, 2, 3, 4},
+				Profiles: []string{"alice"},
+			})
+		}))
+	defer updatesSrv.Close()
+	api := srv.API
+	api.Updates = updatesSrv.URL
+
+	firstSeen := time.Date(2021, 5, 25, 10, 0, 0, 0, time.UTC)
+	storyCache := cache.New(time.Minute, time.Minute)
+	for _, story := range []Story{
+		{ID: 1, Title: "Original", Score: 10, Timestamp: 1000, FirstSeen: firstSeen},
+		{ID: 2, Title: "Killed", Score: 1, Timestamp: 1000, FirstSeen: firstSeen},
+		{ID: 4, Title: "Unreachable", Timestamp: 1000, FirstSeen: firstSeen},
+		{ID: 5, Title: "Unchanged", Timestamp: 1000, FirstSeen: firstSeen},
+	} {
+		storyCache.Set(storyKey(story.ID), story, cache.NoExpiration)
+	}
+	config := DefaultConfig()
+	itemCache := NewItemCache(config)
+	itemCache.SetDefault("3", Story{ID: 3, Title: "Original", Timestamp: 1000, FirstSeen: firstSeen})
+	userCache := cache.New(time.Minute, time.Minute)
+	userCache.SetDefault("alice", User{ID: "alice"})
+	userCache.SetDefault("bob", User{ID: "bob"})
+
+	updated, err := updateStories(context.Background(), api, config, storyCache, itemCache, userCache)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, updated)
+
+	// Only the cached items which changed are looked up again
+	assert.Equal(t, 1, srv.Lookups(3))
+	assert.Equal(t, 0, srv.Lookups(5))
+	_, found := storyCache.Get("3")
+	assert.False(t, found)
+
+	// Items of the item cache still expire
+	story, expires, found := itemCache.GetWithExpiration("3")
+	if assert.True(t, found) {
+		assert.Equal(t, "Edited", story.(Story).Title)
+		assert.False(t, expires.IsZero())
+	}
+
+	story, _ = storyCache.Get("1")
+	assert.Equal(t, "Renamed", story.(Story).Title)
+	assert.Equal(t, 50, story.(Story).Score)
+	assert.Equal(t, 12, story.(Story).Descendants)
+	assert.Equal(t, firstSeen, story.(Story).FirstSeen)
+
+	story, _ = storyCache.Get("4")
+	assert.Equal(t, "Unreachable", story.(Story).Title)
+
+	_, found = userCache.Get("alice")
+	assert.False(t, found)
+	_, found = userCache.Get("bob")
+	assert.True(t, found)
+
+	// Killed stories drop out of the feeds
+	stories, _, err := getStories(context.Background(), api, config, []StoryID{1, 2, 5}, storyCache)
+	assert.Nil(t, err)
+	assert.Len(t, stories, 2)
+}
@@ -0,0 +1,194 @@
+package hackernews
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	HNSubmittedURL = "https://news.ycombinator.com/submitted?id=%s"
+	HNThreadsURL   = "https://news.ycombinator.com/threads?id=%s"
+)
+
+var usernameRE = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// User is a Hacker News user, with the IDs of the items they submitted,
+// newest first
+type User struct {
+	ID        string    `json:"id"`
+	Created   int64     `json:"created"`
+	Karma     int       `json:"karma"`
+	Submitted []StoryID `json:"submitted"`
+}
+
+func getUser(ctx context.Context, api HackerNewsAPI, username string, timeout time.Duration) (User, error) {
+	var user User
+
+	url := fmt.Sprintf(api.User, username)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return User{}, err
+	}
+	client := http.Client{
+		Timeout: timeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return User{}, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return User{}, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := json.Unmarshal(body, &user); err != nil {
+		return User{}, fmt.Errorf("%s: %v", url, err)
+	}
+	// Unknown users are served as null
+	if user.ID == "" {
+		return User{}, fmt.Errorf("user %s: %w", username, feedkit.ErrNotFound)
+	}
+	return user, nil
+}
+
+func getUserFromCache(ctx context.Context, api HackerNewsAPI, username string, userCache *cache.Cache, timeout time.Duration) (User, error) {
+	user, found := userCache.Get(username)
+	feedkit.ObserveCacheLookup(UserCacheName, found)
+	if !found {
+		log.Print("Fetching user ", username)
+		start := time.Now()
+		u, err := getUser(ctx, api, username, timeout)
+		feedkit.ObserveUpstream(SourceName, "getUser", start, err)
+		if err != nil {
+			return User{}, err
+		}
+		user = u
+		userCache.SetDefault(username, user)
+		feedkit.SetCacheSize(UserCacheName, userCache.ItemCount())
+	}
+	return user.(User), nil
+}
+
+// UserActivity is one of the feeds of a user's items
+type UserActivity struct {
+	Path  string                // Path of the feed below the user
+	Title string                // Feed title, given the username
+	URL   string                // Page of the items on Hacker News
+	Match func(item Story) bool // Whether an item belongs in the feed
+	Item  func(item Story) feedkit.Item
+}
+
+var (
+	UserSubmissions = UserActivity{
+		Path:  "submissions",
+		Title: "Hacker News submissions by %s",
+		URL:   HNSubmittedURL,
+		Match: func(item Story) bool {
+			return item.Submission() && !item.Removed()
+		},
+		Item: storyItem,
+	}
+	UserComments = UserActivity{
+		Path:  "comments",
+		Title: "Hacker News comments by %s",
+		URL:   HNThreadsURL,
+		Match: func(item Story) bool {
+			return item.Type == TypeComment && !item.Removed()
+		},
+		Item: func(item Story) feedkit.Item {
+			return commentItem(item, "")
+		},
+	}
+
+	UserActivities = []UserActivity{UserSubmissions, UserComments}
+)
+
+// UserSource is the feed of the recent submissions or comments of a user,
+// served on /user/{username}/submissions and /user/{username}/comments
+type UserSource struct {
+	API      HackerNewsAPI
+	Config   Config
+	Cache    *cache.Cache // Item cache, shared with the other item feeds
+	Users    *cache.Cache // User cache, shared by all user feeds
+	Username string
+	Activity UserActivity
+}
+
+// newUserSource builds the source of a user feed path
+func newUserSource(api HackerNewsAPI, config Config, itemCache *cache.Cache, userCache *cache.Cache) feedkit.NewSourceFunc {
+	return func(key string) (feedkit.Source, error) {
+		parts := strings.Split(key, "/")
+		if len(parts) != 2 || !usernameRE.MatchString(parts[0]) {
+			return nil, fmt.Errorf("no feed at %q: %w", key, feedkit.ErrNotFound)
+		}
+		for _, activity := range UserActivities {
+			if parts[1] == activity.Path {
+				return &UserSource{
+					API:      api,
+					Config:   config,
+					Cache:    itemCache,
+					Users:    userCache,
+					Username: parts[0],
+					Activity: activity,
+				}, nil
+			}
+		}
+		return nil, fmt.Errorf("no feed at %q: %w", key, feedkit.ErrNotFound)
+	}
+}
+
+func (s *UserSource) Metadata() feedkit.Metadata {
+	title := fmt.Sprintf(s.Activity.Title, s.Username)
+	return feedkit.Metadata{
+		Title:       title,
+		Link:        fmt.Sprintf(s.Activity.URL, s.Username),
+		Description: title,
+	}
+}
+
+// Fetch looks up the UserItems most recent items of the user and returns
+// the ones which belong in the feed, newest first
+func (s *UserSource) Fetch(ctx context.Context) ([]feedkit.Item, []feedkit.ItemError, error) {
+	user, err := getUserFromCache(ctx, s.API, s.Username, s.Users, s.Config.FetchTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := user.Submitted
+	if len(ids) > UserItems {
+		ids = ids[:UserItems]
+	}
+	stories, failures := lookupItems(ctx, s.API, s.Config, ids, s.Cache)
+	if feedkit.TooManyFailures(len(failures), len(ids), s.Config.MaxFailureRatio) {
+		return nil, failures, fmt.Errorf("%d of %d item lookups failed",
+			len(failures), len(ids))
+	}
+	feedkit.SetCacheSize(ItemCacheName, s.Cache.ItemCount())
+
+	sort.Slice(stories, func(i, j int) bool {
+		return stories[i].Timestamp > stories[j].Timestamp
+	})
+	items := make([]feedkit.Item, 0, len(stories))
+	for _, story := range stories {
+		if s.Activity.Match(story) {
+			items = append(items, s.Activity.Item(story))
+		}
+	}
+	return items, failures, nil
+}
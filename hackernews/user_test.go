@@ -0,0 +1,77 @@
+package hackernews
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"duh-uh.com/app/rss-feeds/feedkit"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserSource(t *testing.T) {
+	srv := newItemServer(t, map[StoryID]Story{
+		1: {ID: 1, Type: "story", By: "alice", Title: "Show HN: Thing", Timestamp: 1000},
+		2: {ID: 2, Type: "comment", By: "alice", Text: "Thanks!", Timestamp: 3000},
+		3: {ID: 3, Type: "comment", Deleted: true, Timestamp: 4000},
+		4: {ID: 4, Type: "job", By: "alice", Title: "Hiring", Timestamp: 2000},
+	})
+	defer srv.Close()
+	srv.SetUser(User{ID: "alice", Submitted: []StoryID{3, 2, 4, 1}})
+
+	itemCache := NewItemCache(DefaultConfig())
+	userCache := cache.New(time.Minute, time.Minute)
+	newSource := newUserSource(srv.API, DefaultConfig(), itemCache, userCache)
+
+	fetch := func(key string) ([]feedkit.Item, error) {
+		source, err := newSource(key)
+		if err != nil {
+			return nil, err
+		}
+		items, _, err := source.Fetch(context.Background())
+		return items, err
+	}
+
+	t.Run("Submissions", func(t *testing.T) {
+		items, err := fetch("alice/submissions")
+		assert.Nil(t, err)
+		if assert.Len(t, items, 2) {
+			assert.Equal(t, "Hiring", items[0].Title)
+			assert.Equal(t, "Show HN: Thing", items[1].Title)
+		}
+		// Items expire from the item cache
+		_, expires, found := itemCache.GetWithExpiration("1")
+		assert.True(t, found)
+		assert.False(t, expires.IsZero())
+	})
+
+	t.Run("Comments", func(t *testing.T) {
+		items, err := fetch("alice/comments")
+		assert.Nil(t, err)
+		if assert.Len(t, items, 1) {
+			assert.Equal(t, "Comment by alice", items[0].Title)
+			assert.Equal(t, "Thanks!", items[0].Description)
+		}
+	})
+
+	t.Run("UserCache", func(t *testing.T) {
+		// Users are cached until the cache expires
+		srv.SetUser(User{ID: "alice", Submitted: []StoryID{5, 3, 2, 4, 1}})
+		srv.Set(Story{ID: 5, Type: "story", By: "alice", Title: "New", Timestamp: 5000})
+		items, _ := fetch("alice/submissions")
+		assert.Len(t, items, 2)
+
+		userCache.Flush()
+		items, _ = fetch("alice/submissions")
+		assert.Len(t, items, 3)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		for _, key := range []string{"alice", "alice/favorites", "../x/comments", "bob/comments"} {
+			_, err := fetch(key)
+			assert.True(t, errors.Is(err, feedkit.ErrNotFound), key)
+		}
+	})
+}